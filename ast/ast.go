@@ -1,29 +1,44 @@
 package ast
 
 import (
+	"fmt"
 	"log"
 	"strings"
+
+	"github.com/please-build/gcfg/scanner"
+	"github.com/please-build/gcfg/token"
 )
 
 // Comment is any whitespace or comment that is ignored by this parser
 type Comment struct {
 	Str string
+	Pos token.Pos // Position of the start of the line; token.NoPos if it wasn't read from a file
+	End token.Pos // Position just after the end of the line, excluding the line terminator
 }
 
 // File is an AST representation of a config file
 type File struct {
 	Sections      []*Section
-	CommentsAfter []*Comment // Any comments or whitespace that come at the end of a file
-	Fields        []*Field   // Fields that don't belong to a section
+	CommentsAfter []*Comment     // Any comments or whitespace that come at the end of a file
+	Fields        []*Field       // Fields that don't belong to a section
+	FileSet       *token.FileSet // The file set the positions of the nodes belong to
+
+	bom            bool // whether the file started with a UTF8 BOM sequence
+	noFinalNewline bool // whether the last line of the file was unterminated
 }
 
 type Section struct {
-	HeadingStr     string // The literal value of this section heading
-	Name           string
-	Subsection     string
-	Key            string // Section identifier
-	Fields         []*Field
-	CommentsBefore []*Comment // Any comments or whitespace between this field and whatever came before
+	HeadingStr      string // The literal value of this section heading
+	Name            string
+	Subsection      string
+	Key             string // Section identifier, for matching sections: section names are case insensitive, subsection names aren't
+	Fields          []*Field
+	CommentsBefore  []*Comment // Any comments or whitespace between this field and whatever came before
+	TrailingComment string     // Any comment after the section heading
+	Pos             token.Pos  // Position of the start of the heading line; token.NoPos if it wasn't read from a file
+	End             token.Pos  // Position just after the end of the heading line, excluding the line terminator
+
+	marker string // the comment marker used near the section, for new comments
 }
 
 type Field struct {
@@ -31,13 +46,27 @@ type Field struct {
 	Name            string
 	Value           string
 	TrailingComment string     // Any comments or whitespace after the field value
+	Blank           bool       // Whether the field has no value or '=', e.g. for a boolean set to true
 	CommentsBefore  []*Comment // Any comments or whitespace between this field and whatever came before
+	Pos             token.Pos  // Position of the start of the field's line; token.NoPos if it wasn't read from a file
+	End             token.Pos  // Position just after the end of the field, excluding the line terminator
+
+	marker string // the comment marker used near the field, for new comments
+}
+
+// Position returns the position of p, which must be the position of a node of
+// f. It returns an invalid position for token.NoPos.
+func (f File) Position(p token.Pos) token.Position {
+	if f.FileSet == nil || !p.IsValid() {
+		return token.Position{}
+	}
+	return f.FileSet.Position(p)
 }
 
 // MaybeGetSection returns a pointer to a section with name sectionName and subsection subsectionName.
 // Returns nil if section does not exist.
 func (f File) MaybeGetSection(sectionName, subsectionName string) *Section {
-	sectionKey := getKeyFromSectionAndSubsection(sectionName, subsectionName)
+	sectionKey := makeSectionKey(sectionName, subsectionName)
 	for i, s := range f.Sections {
 		if s.Key == sectionKey {
 			return f.Sections[i]
@@ -46,32 +75,83 @@ func (f File) MaybeGetSection(sectionName, subsectionName string) *Section {
 	return nil
 }
 
+// UnquotedValue returns the value of the field, with quotes removed and escape
+// sequences and continuation lines resolved in the same way as when reading
+// into a config struct. It returns an error if Value isn't a valid value
+// literal, which can only happen if it was set directly.
+func (f Field) UnquotedValue() (string, error) {
+	value, err := scanner.Unquote(f.Value)
+	if err != nil {
+		return "", fmt.Errorf("invalid value %q for field %s: %v", f.Value, f.Name, err)
+	}
+	return value, nil
+}
+
+// SetValue sets the value of the field, quoting and escaping it as needed so
+// that it reads back unchanged. The indentation and spacing before the value,
+// any trailing comment, and the line ending are kept.
+func (f *Field) SetValue(value string) {
+	quoted := scanner.Quote(value)
+	str, cr := f.Str, ""
+	if strings.HasSuffix(str, "\r") {
+		str, cr = str[:len(str)-1], "\r"
+	}
+	prefix := f.Name + " = "
+	if i := strings.Index(str, "="); i >= 0 && !f.Blank {
+		rest := str[i+1:]
+		prefix = str[:i+1] + rest[:len(rest)-len(strings.TrimLeft(rest, " \t"))]
+	} else if str != "" {
+		prefix = indentation(str) + prefix
+	}
+
+	f.Str = strings.TrimRight(prefix+quoted, " \t")
+	if f.TrailingComment != "" {
+		f.Str += " " + f.TrailingComment
+	}
+	f.Str += cr
+	f.Value, f.Blank = quoted, false
+}
+
 // getStr returns the string associated with a field
 func (f Field) getStr() string {
 	if f.Str != "" {
 		return f.Str
 	}
-
-	return f.Name + " = " + f.Value
+	str := f.Name + " = " + f.Value
+	if f.Blank {
+		str = f.Name
+	}
+	if f.TrailingComment != "" {
+		str += " " + f.TrailingComment
+	}
+	return str
 }
 
+var subsectionEscape = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
 // getHeadingStr returns the string associated with a section
 func (s Section) getHeadingStr() string {
 	if s.HeadingStr != "" {
 		return s.HeadingStr
 	}
+	heading := "[" + s.Name + "]"
 	if s.Subsection != "" {
-		return "[" + s.Name + " " + "\"" + s.Subsection + "\"]"
+		heading = "[" + s.Name + " \"" + subsectionEscape.Replace(s.Subsection) + "\"]"
+	}
+	if s.TrailingComment != "" {
+		heading += " " + s.TrailingComment
 	}
-	return "[" + s.Name + "]"
+	return heading
 }
 
-// makeSectionKey creates a key for identifying a section
+// makeSectionKey creates a key for identifying a section. Section names are
+// matched ignoring case and subsection names exactly, as when reading into a
+// config struct.
 func makeSectionKey(name, subsection string) string {
 	if subsection == "" {
 		return strings.ToLower(name)
 	}
-	return strings.ToLower(name) + "&" + strings.ToLower(subsection)
+	return strings.ToLower(name) + "&" + subsection
 }
 
 // printDebug prints an entire AST File to help with debugging.
@@ -5,9 +5,12 @@ import (
 	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/please-build/gcfg/scanner"
+	"github.com/please-build/gcfg/token"
 	"github.com/stretchr/testify/require"
 )
 
@@ -15,7 +18,8 @@ func TestGetSectionKey(t *testing.T) {
 	config := `[FOObar]
 bar = value1
 `
-	file := Read(strings.NewReader(config))
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
 	require.Equal(t, "foobar", file.Sections[0].Key)
 }
 
@@ -27,8 +31,9 @@ newyear = happy
 [Rosaceae]
 MalusDomestica = "Orchard apple"
 `
-	file := Read(strings.NewReader(config))
-	require.Equal(t, "hallmark", file.Sections[0].Name)
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
+	require.Equal(t, "hallMaRk", file.Sections[0].Name)
 	require.Equal(t, 2, file.Sections[0].numFields())
 	require.Equal(t, 1, file.Sections[1].numFields())
 	require.Equal(t, 6, file.numLines())
@@ -61,8 +66,9 @@ newyear = happy
 MalusDomestica = "Orchard apple"
 `
 	// Read config into an ast.File
-	file := Read(strings.NewReader(config))
-	Write(file, "actual")
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
+	require.NoError(t, Write(file, "actual"))
 	defer os.Remove("actual")
 
 	if err := os.WriteFile("expected", []byte(config), 0644); err != nil {
@@ -81,7 +87,8 @@ newyear = happy
 [Rosaceae]
 MalusDomestica = "Orchard apple"
 `
-	file := Read(strings.NewReader(config))
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
 	require.Equal(t, 2, len(file.Sections))
 	require.Equal(t, 2, len(file.Sections[0].Fields))
 	require.Equal(t, 1, len(file.Sections[1].Fields))
@@ -92,7 +99,7 @@ MalusDomestica = "Orchard apple"
 	require.Equal(t, 2, len(file.Sections))
 	require.Equal(t, 2, len(file.Sections[0].Fields))
 	require.Equal(t, 2, len(file.Sections[1].Fields))
-	Write(file, "actual")
+	require.NoError(t, Write(file, "actual"))
 	defer os.Remove("actual")
 
 	expectedResult := `[hallMaRk]
@@ -121,14 +128,15 @@ newyear = happy
 ; trees in the Rosaceae family
 MalusDomestica = "Orchard apple"
 `
-	file := Read(strings.NewReader(config))
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
 	require.Equal(t, 1, len(file.Sections[1].Fields))
 
 	fieldName := "MalusPrunifolia"
 	value := "\"Chinese crabapple\""
 	section := "rosaceae"
 	file = InjectField(file, fieldName, value, section, "", true)
-	Write(file, "actual")
+	require.NoError(t, Write(file, "actual"))
 	defer os.Remove("actual")
 
 	expectedResult := `[hallMaRk]
@@ -160,7 +168,8 @@ newyear = happy
 ; trees in the Rosaceae family
 MalusDomestica = "Orchard apple"
 `
-	file := Read(strings.NewReader(config))
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
 	require.Equal(t, 2, len(file.Sections))
 	require.Equal(t, "rosaceae&subsection", file.Sections[1].Key)
 	require.Equal(t, "[Rosaceae \"subsection\"]", file.Sections[1].HeadingStr)
@@ -170,7 +179,7 @@ MalusDomestica = "Orchard apple"
 	section := "rosaceae"
 	subsection := "subsection"
 	file = InjectField(file, fieldName, value, section, subsection, true)
-	Write(file, "actual")
+	require.NoError(t, Write(file, "actual"))
 	defer os.Remove("actual")
 
 	expectedResult := `[hallMaRk]
@@ -205,7 +214,8 @@ newyear = happy
 ; trees in the Rosaceae family
 MalusDomestica = "Orchard apple"
 `
-	file := Read(strings.NewReader(config))
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
 	require.Equal(t, 2, len(file.Sections))
 	require.Equal(t, "hallmark", file.Sections[0].Key)
 	require.Equal(t, 2, len(file.Sections[0].Fields))
@@ -231,13 +241,16 @@ christmas = merry
 [anotherSeCTion]
 field = value
 `
-	file := Read(strings.NewReader(config))
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
 	require.Equal(t, 3, file.numLines())
 
-	file = Read(strings.NewReader(config1))
+	file, err = Read(strings.NewReader(config1))
+	require.NoError(t, err)
 	require.Equal(t, 4, file.numLines())
 
-	file = Read(strings.NewReader(config2))
+	file, err = Read(strings.NewReader(config2))
+	require.NoError(t, err)
 	require.Equal(t, 8, file.numLines())
 }
 
@@ -255,7 +268,8 @@ newyear = happy
 ; trees in the Rosaceae family
 MalusDomestica = "Orchard apple"
 `
-	file := Read(strings.NewReader(config))
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
 	key := "e"
 	value := "mc2"
 	section := "newSectION"
@@ -263,7 +277,7 @@ MalusDomestica = "Orchard apple"
 	file = InjectField(file, key, value, section, subsection, true)
 	require.Equal(t, 1, len(file.Sections[1].Fields))
 	require.Equal(t, 1, len(file.Sections[2].Fields))
-	Write(file, "actual")
+	require.NoError(t, Write(file, "actual"))
 	defer os.Remove("actual")
 
 	expected := `orange = naranja
@@ -304,13 +318,14 @@ newyear = happy
 ; trees in the Rosaceae family
 MalusDomestica = "Orchard apple"
 `
-	file := Read(strings.NewReader(config))
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
 	key := "newyear"
 	value := "sad"
 	section := "hallmark"
 	subsection := ""
 	file = InjectField(file, key, value, section, subsection, false)
-	Write(file, "actual")
+	require.NoError(t, Write(file, "actual"))
 	defer os.Remove("actual")
 
 	expected := `orange = naranja
@@ -346,11 +361,12 @@ func TestFileIsNotModified(t *testing.T) {
 Value =    blah
 
 `
-	file := Read(strings.NewReader(config))
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
 	require.Equal(t, 1, len(file.Sections[0].Fields))
 	require.Equal(t, 1, len(file.Sections))
 	require.Equal(t, "[Section \"blah\"  ]   ", file.Sections[0].HeadingStr)
-	require.Equal(t, config, string(convertASTToBytes(file)))
+	require.Equal(t, config, writeString(t, file))
 }
 
 func TestDeleteSection(t *testing.T) {
@@ -372,7 +388,8 @@ keep = true
 ; stuff
 stuff = stuff
 `
-	file := Read(strings.NewReader(config))
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
 	require.Equal(t, 5, len(file.Sections))
 
 	require.Equal(t, 1, len(file.Sections[0].Fields))
@@ -387,7 +404,7 @@ stuff = stuff
 ; keep this bit
 keep = true
 `
-	require.Equal(t, expected, string(convertASTToBytes(file)))
+	require.Equal(t, expected, writeString(t, file))
 }
 
 func TestSectionLineHasTrailingComment(t *testing.T) {
@@ -395,9 +412,10 @@ func TestSectionLineHasTrailingComment(t *testing.T) {
 key =   value   
 [bar]
 `
-	file := Read(strings.NewReader(config))
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
 	require.Equal(t, 2, len(file.Sections))
-	require.Equal(t, config, string(convertASTToBytes(file)))
+	require.Equal(t, config, writeString(t, file))
 }
 
 func TestFieldHasTrailingComment(t *testing.T) {
@@ -409,8 +427,9 @@ another = field ; with another comment
 
 
 `
-	file := Read(strings.NewReader(config))
-	require.Equal(t, config, string(convertASTToBytes(file)))
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
+	require.Equal(t, config, writeString(t, file))
 
 	file = InjectField(file, "key", "Zanzibar", "foo", "", false)
 	expected := `[foo  ] ; a comment containing an '='
@@ -421,7 +440,7 @@ another = field ; with another comment
 
 
 `
-	require.Equal(t, expected, string(convertASTToBytes(file)))
+	require.Equal(t, expected, writeString(t, file))
 
 }
 
@@ -432,8 +451,9 @@ key = "value"
 [bar]
 another = field
 `
-	file := Read(strings.NewReader(config))
-	require.Equal(t, config, string(convertASTToBytes(file)))
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
+	require.Equal(t, config, writeString(t, file))
 }
 
 func TestDeleteAllFieldsWithName(t *testing.T) {
@@ -447,7 +467,8 @@ yankee = doodle
 [bar]
 another = field
 `
-	file := Read(strings.NewReader(config))
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
 	file = DeleteAllFieldsWithName(file, "key", "foo", "")
 	expected := `[foo]
 yankee = doodle
@@ -455,7 +476,7 @@ yankee = doodle
 [bar]
 another = field
 `
-	Write(file, "actual")
+	require.NoError(t, Write(file, "actual"))
 	defer os.Remove("actual")
 
 	if err := os.WriteFile("expected", []byte(expected), 0644); err != nil {
@@ -463,7 +484,7 @@ another = field
 	}
 	defer os.Remove("expected")
 
-	require.True(t, deepCompare("actual", "expected"), string(convertASTToBytes(file)))
+	require.True(t, deepCompare("actual", "expected"), writeString(t, file))
 
 }
 
@@ -478,13 +499,14 @@ bar = baz
 fruit = banana
 
 ; a comment
-[  foo "sub "]
+[  foo "  Sub"]
 baz =  bar
 
 [ Fruits  ]
  fruit =   cherry ; comment
 `
-	file := Read(strings.NewReader(config))
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
 	file = MergeAllDuplicateSections(file)
 
 	expected := `
@@ -500,7 +522,7 @@ fruit = banana
 bar = baz
 baz =  bar
 `
-	require.Equal(t, expected, string(convertASTToBytes(file)))
+	require.Equal(t, expected, writeString(t, file))
 }
 
 func TestAppendFieldToSection(t *testing.T) {
@@ -510,7 +532,8 @@ fruit = apple ;; a comment
 [food "vegetables"]
 broccoli = green
 `
-	file := Read(strings.NewReader(config))
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
 	file = AppendFieldToSection(file, "onion", "brown", "food", "vegetables")
 	file = AppendFieldToSection(file, "fruit", "mango", "fruits", "")
 	expected := `[fruits]
@@ -522,7 +545,7 @@ broccoli = green
 onion = brown
 `
 	require.Equal(t, 2, file.Sections[1].numFields())
-	require.Equal(t, expected, string(convertASTToBytes(file)))
+	require.Equal(t, expected, writeString(t, file))
 }
 
 func TestDeleteFieldWithValue(t *testing.T) {
@@ -534,7 +557,8 @@ fruit = papaya
 broccoli = green
 broccoli = red
 `
-	file := Read(strings.NewReader(config))
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
 	file = DeleteFieldWithValue(file, "broccoli", "red", "food", "vegetables")
 	file = DeleteFieldWithValue(file, "fruit", "papaya", "fruits", "")
 	expected := `[fruits]
@@ -545,7 +569,7 @@ broccoli = green
 `
 	require.Equal(t, 1, file.Sections[0].numFields())
 	require.Equal(t, 1, file.Sections[1].numFields())
-	require.Equal(t, expected, string(convertASTToBytes(file)))
+	require.Equal(t, expected, writeString(t, file))
 }
 
 func TestAppendBlankLineToFile(t *testing.T) {
@@ -557,7 +581,8 @@ fruit = papaya
 broccoli = green
 broccoli = red
 `
-	file := Read(strings.NewReader(config))
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
 	file = AppendBlankLineToFile(file)
 	expected := `[fruits]
 fruit = apple ;; a comment
@@ -568,7 +593,7 @@ broccoli = green
 broccoli = red
 
 `
-	require.Equal(t, expected, string(convertASTToBytes(file)))
+	require.Equal(t, expected, writeString(t, file))
 }
 
 func TestAppendBlankLineToCommentFile(t *testing.T) {
@@ -578,7 +603,8 @@ func TestAppendBlankLineToCommentFile(t *testing.T) {
 ; comment
 ; preamble
 `
-	file := Read(strings.NewReader(config))
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
 	file = AppendBlankLineToFile(file)
 	file = AppendBlankLineToFile(file)
 	file = AppendBlankLineToFile(file)
@@ -591,7 +617,7 @@ func TestAppendBlankLineToCommentFile(t *testing.T) {
 
 
 `
-	require.Equal(t, expected, string(convertASTToBytes(file)))
+	require.Equal(t, expected, writeString(t, file))
 }
 
 func TestAppendBlankLineToSection(t *testing.T) {
@@ -604,7 +630,8 @@ fruit = papaya
 vegetable = broccoli
 veg = aubergine
 `
-	file := Read(strings.NewReader(config))
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
 	file, ok := AppendBlankLineToSection(file, "fruits", "")
 	require.True(t, ok)
 	file, ok = AppendBlankLineToSection(file, "vegetables", "")
@@ -620,14 +647,15 @@ vegetable = broccoli
 veg = aubergine
 
 `
-	require.Equal(t, expected, string(convertASTToBytes(file)))
+	require.Equal(t, expected, writeString(t, file))
 }
 
 func TestInjectFieldIntoCommentFile(t *testing.T) {
 	config := `; comment
 ; preamble
 `
-	file := Read(strings.NewReader(config))
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
 	file = InjectField(file, "foo", "bar", "Section", "baz", false)
 	expected := `; comment
 ; preamble
@@ -635,7 +663,7 @@ func TestInjectFieldIntoCommentFile(t *testing.T) {
 [Section "baz"]
 foo = bar
 `
-	require.Equal(t, expected, string(convertASTToBytes(file)))
+	require.Equal(t, expected, writeString(t, file))
 }
 
 func TestConfigFieldRegex(t *testing.T) {
@@ -645,7 +673,8 @@ key=;comment
 key={value}
 key = { value  }{foo}
 `
-	file := Read(strings.NewReader(config))
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
 	require.Equal(t, 4, len(file.Sections[0].Fields))
 	require.Equal(t, "", file.Sections[0].Fields[0].Value)
 	require.Equal(t, "", file.Sections[0].Fields[1].Value)
@@ -653,6 +682,703 @@ key = { value  }{foo}
 	require.Equal(t, "{ value  }{foo}", file.Sections[0].Fields[3].Value)
 }
 
+func TestReadRoundTrip(t *testing.T) {
+	configs := map[string]string{
+		"unicode names":      "[sección \"año\"]\nnúmero = 1\n",
+		"quoted semicolon":   "[section]\nkey = \"a ; b\" ; comment\nother = a\"#\"b\n",
+		"continuation lines": "[section]\nkey = first \\\n  second \\\n  third ; comment\nnext = value\n",
+		"blank boolean":      "[section]\nenabled\ndisabled ; comment\n",
+		"other characters":   "[section]\nkey = $(HOME)/*.go ? !%^\n",
+		"crlf":               "[section]\r\nkey = value\r\n\r\n; comment\r\n",
+		"no final newline":   "[section]\nkey = value",
+		"bom":                "\ufeff[section]\nkey = value\n",
+		"whitespace":         "  \t\n\t[ section ]\t\n\t key\t=\tvalue\t\n",
+		"hash comments":      "# comment\n[section] # comment\nkey = value # comment\n",
+		"empty":              "",
+	}
+	for name, config := range configs {
+		t.Run(name, func(t *testing.T) {
+			file, err := Read(strings.NewReader(config))
+			require.NoError(t, err)
+			require.Equal(t, config, writeString(t, file))
+		})
+	}
+
+	for _, name := range []string{"gcfg_test.gcfg", "gcfg_unicode_test.gcfg", "issue12.gcfg", "notepad.ini"} {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("..", "testdata", name))
+			require.NoError(t, err)
+			file, err := Read(bytes.NewReader(data))
+			require.NoError(t, err)
+			require.Equal(t, string(data), writeString(t, file))
+		})
+	}
+}
+
+func TestReadValues(t *testing.T) {
+	config := `[Section "Sub \"Name\""]
+key = "a ; b" ; comment
+multi = first \
+  second
+enabled
+`
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(file.Sections))
+	s := file.Sections[0]
+	require.Equal(t, "Section", s.Name)
+	require.Equal(t, `Sub "Name"`, s.Subsection)
+	require.Equal(t, 3, len(s.Fields))
+	require.Equal(t, `"a ; b"`, s.Fields[0].Value)
+	require.Equal(t, "; comment", s.Fields[0].TrailingComment)
+	require.Equal(t, "first \\\n  second", s.Fields[1].Value)
+	require.Equal(t, "multi = first \\\n  second", s.Fields[1].Str)
+	require.Equal(t, "enabled", s.Fields[2].Name)
+	require.True(t, s.Fields[2].Blank)
+}
+
+func TestReadErrors(t *testing.T) {
+	for config, expected := range map[string]string{
+		"[section\n":                 "1:9: expected subsection name or right bracket",
+		"[section \"sub\"\n":         "1:15: expected right bracket",
+		"[section \"\"]\n":           "1:10: empty subsection name",
+		"[]\n":                       "1:2: expected section name",
+		"[section] key\n":            "1:11: expected EOL, EOF, or comment",
+		"[section]\nkey value\n":     "2:5: expected '='",
+		"[section]\nkey = \"value\n": "2:7: string not terminated",
+		"[section]\n= value\n":       "2:1: expected section header or variable declaration",
+		"[section]\nkey = a \\ b\n":  "2:7: unquoted '\\' must be followed by new line or double quote",
+	} {
+		_, err := Read(strings.NewReader(config))
+		require.EqualError(t, err, expected, "%q", config)
+		var errs scanner.ErrorList
+		require.ErrorAs(t, err, &errs)
+	}
+
+	require.PanicsWithValue(t, "could not read config: 2:5: expected '='", func() {
+		MustRead(strings.NewReader("[section]\nkey value\n"))
+	})
+}
+
+func TestWriteErrors(t *testing.T) {
+	_, err := convertASTToBytes(File{Sections: []*Section{{}}})
+	require.EqualError(t, err, "cannot write a section without a name")
+	_, err = convertASTToBytes(File{Sections: []*Section{{Name: "section", Fields: []*Field{{Value: "value"}}}}})
+	require.EqualError(t, err, "cannot write a field without a name")
+	require.Error(t, Write(File{Fields: []*Field{{}}}, "actual"))
+	_, err = os.Stat("actual")
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestPositions(t *testing.T) {
+	config := `; comment
+[section]
+key = first \
+  second
+  other = value ; comment
+`
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
+	position := func(p token.Pos) string {
+		return file.Position(p).String()
+	}
+
+	s := file.Sections[0]
+	require.Equal(t, "1:1", position(s.CommentsBefore[0].Pos))
+	require.Equal(t, "1:10", position(s.CommentsBefore[0].End))
+	require.Equal(t, "2:1", position(s.Pos))
+	require.Equal(t, "2:10", position(s.End))
+	require.Equal(t, "3:1", position(s.Fields[0].Pos))
+	require.Equal(t, "4:9", position(s.Fields[0].End))
+	require.Equal(t, "5:1", position(s.Fields[1].Pos))
+	require.Equal(t, "5:26", position(s.Fields[1].End))
+	require.Equal(t, s.Fields[1].Str, config[file.Position(s.Fields[1].Pos).Offset:file.Position(s.Fields[1].End).Offset])
+
+	file = InjectField(file, "new", "value", "section", "", true)
+	require.Equal(t, token.NoPos, s.Fields[2].Pos)
+	require.Equal(t, token.Position{}, file.Position(s.Fields[2].Pos))
+
+	// CRLF line endings aren't included either.
+	file, err = Read(strings.NewReader(strings.ReplaceAll(config, "\n", "\r\n")))
+	require.NoError(t, err)
+	s = file.Sections[0]
+	require.Equal(t, "1:10", position(s.CommentsBefore[0].End))
+	require.Equal(t, "2:10", position(s.End))
+	require.Equal(t, "4:9", position(s.Fields[0].End))
+	require.Equal(t, "5:26", position(s.Fields[1].End))
+}
+
+func TestReadFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.gcfg")
+	require.NoError(t, os.WriteFile(filename, []byte("[section]\nkey = value\n"), 0644))
+	file, err := ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, filename+":2:1", file.Position(file.Sections[0].Fields[0].Pos).String())
+
+	require.NoError(t, os.WriteFile(filename, []byte("[section]\nkey value\n"), 0644))
+	_, err = ReadFile(filename)
+	require.EqualError(t, err, filename+":2:5: expected '='")
+
+	_, err = ReadFile(filepath.Join(t.TempDir(), "missing.gcfg"))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestSectionNameCase(t *testing.T) {
+	config := `[Fruits]
+fruit = apple
+
+[Foo "Sub"]
+bar = baz
+
+[foo "sub"]
+baz = bar
+`
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
+	require.Equal(t, "Fruits", file.Sections[0].Name)
+	require.Equal(t, "Sub", file.Sections[1].Subsection)
+	require.Same(t, file.Sections[0], file.MaybeGetSection("FRUITS", ""))
+	require.Same(t, file.Sections[1], file.MaybeGetSection("foo", "Sub"))
+	require.Same(t, file.Sections[2], file.MaybeGetSection("FOO", "sub"))
+	require.Nil(t, file.MaybeGetSection("foo", "SUB"))
+
+	file = MergeAllDuplicateSections(file)
+	require.Equal(t, 3, len(file.Sections))
+
+	file = InjectField(file, "e", "mc2", "NewSection", `Sub "Name"`, true)
+	file = InjectField(file, "e", "mc3", "newsection", `Sub "Name"`, false)
+	require.Equal(t, config+`
+[NewSection "Sub \"Name\""]
+e = mc3
+`, writeString(t, file))
+}
+
+func TestWriteTo(t *testing.T) {
+	config := "[section]\nkey = value\n"
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
+	var b bytes.Buffer
+	n, err := file.WriteTo(&b)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(config)), n)
+	require.Equal(t, config, b.String())
+
+	_, err = File{Fields: []*Field{{}}}.WriteTo(&b)
+	require.Error(t, err)
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config.gcfg")
+	file, err := Read(strings.NewReader("[section]\nkey = value\n"))
+	require.NoError(t, err)
+
+	require.NoError(t, file.WriteFile(filename))
+	info, err := os.Stat(filename)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	require.NoError(t, os.Chmod(filename, 0600))
+	file = InjectField(file, "other", "value", "section", "", true)
+	require.NoError(t, file.WriteFile(filename))
+	data, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "[section]\nkey = value\nother = value\n", string(data))
+	info, err = os.Stat(filename)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// Symbolic links are followed, rather than replaced.
+	link := filepath.Join(dir, "link.gcfg")
+	require.NoError(t, os.Symlink(filename, link))
+	file = InjectField(file, "key", "new", "section", "", false)
+	require.NoError(t, file.WriteFile(link))
+	data, err = os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "[section]\nkey = new\nother = value\n", string(data))
+	info, err = os.Lstat(link)
+	require.NoError(t, err)
+	require.Equal(t, os.ModeSymlink, info.Mode().Type())
+
+	// A failed write leaves the file as it was, and no temporary files.
+	require.Error(t, File{Fields: []*Field{{}}}.WriteFile(filename))
+	data, err = os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "[section]\nkey = new\nother = value\n", string(data))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(entries))
+}
+
+func TestUnquotedValue(t *testing.T) {
+	config := `[section]
+plain = value
+quoted = "Chinese crabapple" ; comment
+escaped = "a \"b\" \\ c\td"
+continued = first \
+  second
+blank
+`
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
+	var values []string
+	for _, field := range file.Sections[0].Fields {
+		value, err := field.UnquotedValue()
+		require.NoError(t, err)
+		values = append(values, value)
+	}
+	require.Equal(t, []string{"value", "Chinese crabapple", "a \"b\" \\ c\td", "first   second", ""}, values)
+
+	_, err = Field{Name: "key", Value: `"unterminated`}.UnquotedValue()
+	require.EqualError(t, err, `invalid value "\"unterminated" for field key: missing end quote`)
+}
+
+func TestSetValue(t *testing.T) {
+	config := `[section]
+  plain   =   value
+quoted = "Chinese crabapple" ; comment
+  blank ; comment
+empty =
+`
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
+	fields := file.Sections[0].Fields
+	fields[0].SetValue(" padded ")
+	fields[1].SetValue("a;b")
+	fields[2].SetValue("true")
+	fields[3].SetValue("")
+	file.Sections[0].Fields = append(fields, NewField("new", `say "hi"`))
+	require.Equal(t, `[section]
+  plain   =   " padded "
+quoted = "a;b" ; comment
+  blank = true ; comment
+empty =
+new = "say \"hi\""
+`, writeString(t, file))
+
+	for _, field := range file.Sections[0].Fields {
+		require.False(t, field.Blank)
+	}
+	value, err := file.Sections[0].Fields[4].UnquotedValue()
+	require.NoError(t, err)
+	require.Equal(t, `say "hi"`, value)
+
+	file, err = Read(strings.NewReader("[section]\r\nx = 1 ; c\r\n  y\r\n"))
+	require.NoError(t, err)
+	file.Sections[0].Fields[0].SetValue("2")
+	file.Sections[0].Fields[1].SetValue("3")
+	require.Equal(t, "[section]\r\nx = 2 ; c\r\n  y = 3\r\n", writeString(t, file))
+}
+
+func TestComments(t *testing.T) {
+	config := `# file comment
+
+# section doc
+[section] # about the section
+# unrelated
+
+  # first line
+  #second line
+  key = value # about key
+other = "a # b"
+  plain = value
+`
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
+	s := file.Sections[0]
+	require.Equal(t, "section doc", s.Doc())
+	require.Equal(t, "# about the section", s.TrailingComment)
+	require.Equal(t, "first line\nsecond line", s.Fields[0].Doc())
+	require.Equal(t, "", s.Fields[1].Doc())
+
+	s.SetDoc("")
+	s.SetTrailingComment("")
+	s.Fields[0].SetDoc("DEPRECATED: use other")
+	s.Fields[0].SetTrailingComment("not used")
+	s.Fields[1].SetDoc("a\n\nb")
+	s.Fields[1].SetTrailingComment("other comment")
+	s.Fields[2].SetDoc("plain doc")
+	require.Equal(t, `# file comment
+
+[section]
+# unrelated
+
+  # DEPRECATED: use other
+  key = value # not used
+# a
+#
+# b
+other = "a # b" # other comment
+  # plain doc
+  plain = value
+`, writeString(t, file))
+	require.Equal(t, "a\n\nb", s.Fields[1].Doc())
+
+	file = InjectField(file, "new", "value", "new", "", true)
+	file.Sections[1].Fields[0].SetTrailingComment("added")
+	require.Equal(t, "new = value # added", file.Sections[1].Fields[0].Str)
+}
+
+func TestCommentsDefaultMarker(t *testing.T) {
+	file := InjectField(File{}, "key", "value", "section", "", true)
+	s := file.Sections[0]
+	s.SetDoc("section doc")
+	s.SetTrailingComment("heading")
+	s.Fields[0].SetDoc("key doc")
+	s.Fields[0].SetTrailingComment("trailing")
+	s.Fields[0].SetValue("new value")
+	s.Fields = append(s.Fields, &Field{Name: "blank", Blank: true, TrailingComment: "; set"})
+	require.Equal(t, `
+; section doc
+[section] ; heading
+; key doc
+key = new value ; trailing
+blank ; set
+`, writeString(t, file))
+}
+
+func TestCommentsCRLF(t *testing.T) {
+	file, err := Read(strings.NewReader("[a]\r\nx = 1 ; c\r\n"))
+	require.NoError(t, err)
+	s := file.Sections[0]
+	s.SetDoc("section doc")
+	s.Fields[0].SetDoc("first\nsecond")
+	s.Fields[0].SetTrailingComment("new")
+	require.Equal(t, "; section doc\r\n[a]\r\n; first\r\n; second\r\nx = 1 ; new\r\n", writeString(t, file))
+	require.Equal(t, "section doc", s.Doc())
+	require.Equal(t, "first\nsecond", s.Fields[0].Doc())
+}
+
+func TestSetTrailingCommentLineBreaks(t *testing.T) {
+	config := "[a] ; heading\nx = 1 ; c\n"
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
+	s := file.Sections[0]
+	for _, text := range []string{"x\nevil = 1", "x\r", "\n"} {
+		require.False(t, s.SetTrailingComment(text))
+		require.False(t, s.Fields[0].SetTrailingComment(text))
+	}
+	require.Equal(t, config, writeString(t, file))
+	require.True(t, s.Fields[0].SetTrailingComment("new"))
+	require.Equal(t, "x = 1 ; new", s.Fields[0].Str)
+}
+
+func TestInsertField(t *testing.T) {
+	config := `[fruits]
+apple = red
+
+; unrelated
+
+; doc for banana
+banana = yellow ; trailing
+cherry = red
+`
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
+	apple, banana, cherry := file.Sections[0].Fields[0], file.Sections[0].Fields[1], file.Sections[0].Fields[2]
+
+	before := NewField("avocado", "green")
+	before.SetDoc("doc for avocado")
+	file, ok := InsertFieldBefore(file, banana, before)
+	require.True(t, ok)
+	file, ok = InsertFieldAfter(file, cherry, NewField("damson", "purple"))
+	require.True(t, ok)
+	file, ok = InsertFieldBefore(file, apple, NewField("acerola", "red"))
+	require.True(t, ok)
+	require.Equal(t, `[fruits]
+acerola = red
+apple = red
+
+; unrelated
+
+; doc for avocado
+avocado = green
+; doc for banana
+banana = yellow ; trailing
+cherry = red
+damson = purple
+`, writeString(t, file))
+
+	_, ok = InsertFieldAfter(file, NewField("missing", ""), NewField("new", ""))
+	require.False(t, ok)
+}
+
+func TestInsertFieldWithoutSection(t *testing.T) {
+	file, err := Read(strings.NewReader("key = value\n"))
+	require.NoError(t, err)
+	file, ok := InsertFieldAfter(file, file.Fields[0], NewField("other", "value"))
+	require.True(t, ok)
+	require.Equal(t, "key = value\nother = value\n", writeString(t, file))
+}
+
+func TestInsertSection(t *testing.T) {
+	config := `# preamble
+
+# doc for fruits
+[fruits]
+apple = red
+
+[vegetables]
+carrot = orange
+# the end
+`
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
+	fruits, vegetables := file.Sections[0], file.Sections[1]
+
+	nuts := NewSection("nuts", "")
+	nuts.Fields = append(nuts.Fields, NewField("almond", "brown"))
+	file, ok := InsertSectionBefore(file, fruits, nuts)
+	require.True(t, ok)
+	nuts.SetDoc("doc for nuts")
+
+	herbs := NewSection("herbs", `"fresh"`)
+	herbs.Fields = append(herbs.Fields, NewField("basil", "green"))
+	file, ok = InsertSectionAfter(file, fruits, herbs)
+	require.True(t, ok)
+	file, ok = InsertSectionAfter(file, vegetables, NewSection("grains", ""))
+	require.True(t, ok)
+	require.Equal(t, `# preamble
+
+# doc for nuts
+[nuts]
+almond = brown
+
+# doc for fruits
+[fruits]
+apple = red
+
+[herbs "\"fresh\""]
+basil = green
+
+[vegetables]
+carrot = orange
+
+[grains]
+# the end
+`, writeString(t, file))
+
+	_, ok = InsertSectionBefore(file, NewSection("missing", ""), NewSection("new", ""))
+	require.False(t, ok)
+}
+
+func TestRenameSection(t *testing.T) {
+	config := `; doc for old
+[ Old  "sub" ] ; trailing
+key = value
+
+[other]
+x = y
+`
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
+	file, ok := RenameSection(file, "old", "sub", "New", `a "b"`)
+	require.True(t, ok)
+	require.Equal(t, `; doc for old
+[ New "a \"b\"" ] ; trailing
+key = value
+
+[other]
+x = y
+`, writeString(t, file))
+	require.NotNil(t, file.MaybeGetSection("new", `a "b"`))
+
+	_, ok = RenameSection(file, "old", "sub", "new", "")
+	require.False(t, ok)
+
+	renamed := writeString(t, file)
+	for _, tt := range []struct{ name, subsection string }{
+		{"", ""},
+		{"bad name", ""},
+		{"bad;name", ""},
+		{"1st", ""},
+		{"other", "a\nb"},
+		{"other", "a\r"},
+	} {
+		file, ok = RenameSection(file, "new", `a "b"`, tt.name, tt.subsection)
+		require.False(t, ok, "%q %q", tt.name, tt.subsection)
+	}
+	require.Equal(t, renamed, writeString(t, file))
+}
+
+func TestRenameSectionMerge(t *testing.T) {
+	config := `[target]
+a = 1
+
+; doc for old
+[old] ; trailing
+; doc for b
+b = 2 ; about b
+
+[Old] ; empty
+
+; comment in empty section
+[other]
+c = 3
+`
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
+	file, ok := RenameSection(file, "OLD", "", "target", "")
+	require.True(t, ok)
+	require.Equal(t, 2, len(file.Sections))
+	require.Equal(t, `[target]
+a = 1
+; doc for old
+; trailing
+; doc for b
+b = 2 ; about b
+; empty
+
+; comment in empty section
+[other]
+c = 3
+`, writeString(t, file))
+}
+
+func TestRenameField(t *testing.T) {
+	config := `[section]
+  Old   =  value ; trailing
+old
+other = old
+[section "sub"]
+old = value
+`
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
+	file, ok := RenameField(file, "Section", "", "old", "new")
+	require.True(t, ok)
+	require.Equal(t, `[section]
+  new   =  value ; trailing
+new
+other = old
+[section "sub"]
+old = value
+`, writeString(t, file))
+
+	_, ok = RenameField(file, "section", "", "old", "new")
+	require.False(t, ok)
+
+	renamed := writeString(t, file)
+	for _, name := range []string{"", "bad name; y", "x=1", "-x", "a\nb"} {
+		file, ok = RenameField(file, "section", "", "new", name)
+		require.False(t, ok, name)
+	}
+	require.Equal(t, renamed, writeString(t, file))
+	_, err = Read(strings.NewReader(renamed))
+	require.NoError(t, err)
+}
+
+func TestMoveField(t *testing.T) {
+	config := `[from]
+a = 1
+
+; doc for key
+key = value ; trailing
+b = 2
+key = other
+
+[to]
+c = 3
+`
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
+	file, ok := MoveField(file, "from", "", "to", "", "KEY")
+	require.True(t, ok)
+	require.Equal(t, `[from]
+a = 1
+
+b = 2
+
+[to]
+c = 3
+; doc for key
+key = value ; trailing
+key = other
+`, writeString(t, file))
+
+	file, ok = MoveField(file, "from", "", "new", "sub", "b")
+	require.True(t, ok)
+	require.Equal(t, `[from]
+a = 1
+
+
+[to]
+c = 3
+; doc for key
+key = value ; trailing
+key = other
+
+[new "sub"]
+b = 2
+`, writeString(t, file))
+
+	_, ok = MoveField(file, "from", "", "to", "", "missing")
+	require.False(t, ok)
+}
+
+func TestGet(t *testing.T) {
+	config := `[section]
+name = first
+Multi = a
+multi = "b c"
+enabled
+[remote "origin"]
+url = one
+[other]
+[Section]
+NAME = second ; comment
+multi
+multi = d
+multi = e
+[remote]
+url = default
+[remote "Origin"]
+url = two
+[remote "origin"]
+url = three
+`
+	file, err := Read(strings.NewReader(config))
+	require.NoError(t, err)
+
+	value, ok := file.Get("SECTION", "", "name")
+	require.True(t, ok)
+	require.Equal(t, "second", value)
+	value, ok = file.Get("section", "", "enabled")
+	require.True(t, ok)
+	require.Equal(t, "true", value)
+	value, ok = file.Get("section", "", "multi")
+	require.True(t, ok)
+	require.Equal(t, "e", value)
+	value, ok = file.Get("remote", "origin", "url")
+	require.True(t, ok)
+	require.Equal(t, "three", value)
+	_, ok = file.Get("section", "", "missing")
+	require.False(t, ok)
+	_, ok = file.Get("remote", "ORIGIN", "url")
+	require.False(t, ok)
+
+	require.Equal(t, []string{"d", "e"}, file.GetAll("section", "", "multi"))
+	require.Equal(t, []string{"one", "three"}, file.GetAll("remote", "origin", "url"))
+	require.Equal(t, []string{"first", "second"}, file.GetAll("section", "", "name"))
+	require.Nil(t, file.GetAll("section", "", "enabled"))
+	require.Nil(t, file.GetAll("other", "", "missing"))
+
+	require.True(t, file.Has("section", "", "ENABLED"))
+	require.True(t, file.Has("remote", "Origin", "url"))
+	require.False(t, file.Has("other", "", "url"))
+
+	require.Equal(t, []string{"origin", "", "Origin"}, file.Subsections("Remote"))
+	require.Equal(t, []string{""}, file.Subsections("other"))
+	require.Nil(t, file.Subsections("missing"))
+}
+
+// writeString returns the contents of f, as written by Write.
+func writeString(t *testing.T, f File) string {
+	data, err := convertASTToBytes(f)
+	require.NoError(t, err)
+	return string(data)
+}
+
 const chunkSize = 64000
 
 func deepCompare(file1, file2 string) bool {
@@ -0,0 +1,214 @@
+package ast
+
+import (
+	"strings"
+)
+
+// Doc returns the text of the section's doc comment: the comment lines directly
+// before its heading, with no blank line in between. The comment markers and
+// a single space after them are removed, and the lines are joined by "\n".
+func (s Section) Doc() string {
+	return docText(s.CommentsBefore)
+}
+
+// SetDoc replaces the section's doc comment with text, which may have several
+// lines, or removes it if text is empty. See Field.SetDoc.
+func (s *Section) SetDoc(text string) {
+	s.CommentsBefore = setDoc(s.CommentsBefore, text, s.getHeadingStr(), s.TrailingComment, s.marker)
+}
+
+// SetTrailingComment replaces the comment after the section's heading with
+// text, or removes it if text is empty. See Field.SetTrailingComment.
+func (s *Section) SetTrailingComment(text string) bool {
+	if strings.ContainsAny(text, "\r\n") {
+		return false
+	}
+	str := s.getHeadingStr()
+	s.TrailingComment = makeTrailingComment(text, s.TrailingComment, s.CommentsBefore, s.marker)
+	s.HeadingStr = replaceTrailingComment(str, s.TrailingComment)
+	return true
+}
+
+// Doc returns the text of the field's doc comment: the comment lines directly
+// before it, with no blank line in between. The comment markers and a single
+// space after them are removed, and the lines are joined by "\n".
+func (f Field) Doc() string {
+	return docText(f.CommentsBefore)
+}
+
+// commentMarker returns the marker of the first comment in the file, or "" if
+// there are none.
+func (f File) commentMarker() string {
+	var strs []string
+	add := func(comments []*Comment, trailingComment string) {
+		for _, c := range comments {
+			strs = append(strs, c.Str)
+		}
+		strs = append(strs, trailingComment)
+	}
+	for _, field := range f.Fields {
+		add(field.CommentsBefore, field.TrailingComment)
+	}
+	for _, s := range f.Sections {
+		add(s.CommentsBefore, s.TrailingComment)
+		for _, field := range s.Fields {
+			add(field.CommentsBefore, field.TrailingComment)
+		}
+	}
+	add(f.CommentsAfter, "")
+	return firstMarker(strs...)
+}
+
+// SetDoc replaces the field's doc comment with text, which may have several
+// lines, or removes it if text is empty. Other comments before the field, and
+// blank lines, are kept.
+//
+// The new comment lines are indented like the field, and use the same comment
+// marker as the existing doc comment, or otherwise as the field's other
+// comments or the comments nearest to it in the file. If there are none, ";"
+// is used.
+func (f *Field) SetDoc(text string) {
+	f.CommentsBefore = setDoc(f.CommentsBefore, text, f.getStr(), f.TrailingComment, f.marker)
+}
+
+// SetTrailingComment replaces the comment after the field's value with text,
+// or removes it if text is empty. The comment uses the same marker as the
+// existing trailing comment, or otherwise as chosen by SetDoc. It returns false,
+// leaving the field unchanged, if text has a line break.
+func (f *Field) SetTrailingComment(text string) bool {
+	if strings.ContainsAny(text, "\r\n") {
+		return false
+	}
+	str := f.getStr()
+	f.TrailingComment = makeTrailingComment(text, f.TrailingComment, f.CommentsBefore, f.marker)
+	f.Str = replaceTrailingComment(str, f.TrailingComment)
+	return true
+}
+
+// commentText splits a comment line into its indentation, marker and text.
+// ok is false if the line isn't a comment.
+func commentText(str string) (indent, marker, text string, ok bool) {
+	trimmed := strings.TrimLeft(str, " \t")
+	if trimmed == "" || trimmed[0] != ';' && trimmed[0] != '#' {
+		return "", "", "", false
+	}
+	text = strings.TrimSuffix(trimmed[1:], "\r")
+	return str[:len(str)-len(trimmed)], trimmed[:1], strings.TrimPrefix(text, " "), true
+}
+
+// docStart returns the index of the first line of the doc comment at the end
+// of comments, or len(comments) if there is none.
+func docStart(comments []*Comment) int {
+	i := len(comments)
+	for i > 0 {
+		if _, _, _, ok := commentText(comments[i-1].Str); !ok {
+			break
+		}
+		i--
+	}
+	return i
+}
+
+func docText(comments []*Comment) string {
+	var lines []string
+	for _, c := range comments[docStart(comments):] {
+		_, _, text, _ := commentText(c.Str)
+		lines = append(lines, text)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// commentMarker returns the marker used by the first of the given comments
+// that has one, or ";" if none do.
+func commentMarker(comments ...string) string {
+	if marker := firstMarker(comments...); marker != "" {
+		return marker
+	}
+	return ";"
+}
+
+// firstMarker returns the marker used by the first of the given comments that
+// has one, or "" if none do.
+func firstMarker(comments ...string) string {
+	for _, c := range comments {
+		if _, marker, _, ok := commentText(c); ok {
+			return marker
+		}
+	}
+	return ""
+}
+
+// nearestFirst returns the lines of the comments before a node, starting with
+// the one nearest to it.
+func nearestFirst(comments []*Comment) []string {
+	strs := make([]string, 0, len(comments))
+	for i := len(comments) - 1; i >= 0; i-- {
+		strs = append(strs, comments[i].Str)
+	}
+	return strs
+}
+
+func setDoc(comments []*Comment, text, str, trailingComment, nearby string) []*Comment {
+	start := docStart(comments)
+	marker := commentMarker(append(nearestFirst(comments), trailingComment, nearby)...)
+
+	res := append([]*Comment{}, comments[:start]...)
+	if text == "" {
+		return res
+	}
+	indent, cr := indentation(str), ""
+	if strings.HasSuffix(str, "\r") {
+		cr = "\r"
+	}
+	for _, line := range strings.Split(text, "\n") {
+		res = append(res, &Comment{Str: strings.TrimRight(indent+marker+" "+line, " ") + cr})
+	}
+	return res
+}
+
+func makeTrailingComment(text, old string, comments []*Comment, nearby string) string {
+	if text == "" {
+		return ""
+	}
+	return commentMarker(append(append([]string{old}, nearestFirst(comments)...), nearby)...) + " " + text
+}
+
+// replaceTrailingComment replaces the trailing comment of the line str, if it
+// has one, with comment. Any line continuation after the comment is kept.
+func replaceTrailingComment(str, comment string) string {
+	cr := ""
+	if strings.HasSuffix(str, "\r") {
+		str, cr = str[:len(str)-1], "\r"
+	}
+	if i := trailingCommentStart(str); i >= 0 {
+		str = str[:i]
+	}
+	str = strings.TrimRight(str, " \t")
+	if comment != "" {
+		str += " " + comment
+	}
+	return str + cr
+}
+
+// trailingCommentStart returns the index of the trailing comment of the last
+// line of str, or -1 if it has none. Comment markers in quotes or after a
+// backslash aren't counted.
+func trailingCommentStart(str string) int {
+	inQuote := false
+	for i := 0; i < len(str); i++ {
+		switch c := str[i]; {
+		case c == '\\':
+			i++
+		case c == '"':
+			inQuote = !inQuote
+		case !inQuote && (c == ';' || c == '#'):
+			return i
+		}
+	}
+	return -1
+}
+
+// indentation returns the leading whitespace of str.
+func indentation(str string) string {
+	return str[:len(str)-len(strings.TrimLeft(str, " \t"))]
+}
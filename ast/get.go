@@ -0,0 +1,87 @@
+package ast
+
+import (
+	"strings"
+)
+
+// Get returns the value of the field called name in the section [section "subsection"], as it
+// would be read into a config struct: if the field is given several times, possibly in several
+// sections with the same name, the last value is used. A blank field, such as a boolean given
+// without a value, has the value "true". Values are unquoted as by Field.UnquotedValue, or
+// returned as is if they aren't valid. Returns !ok if there is no such field.
+func (f File) Get(section, subsection, name string) (string, bool) {
+	fields := f.fields(section, subsection, name)
+	if len(fields) == 0 {
+		return "", false
+	}
+	last := fields[len(fields)-1]
+	if last.Blank {
+		return "true", true
+	}
+	return unquotedValue(last), true
+}
+
+// GetAll returns the values of the multi-valued field called name in the section
+// [section "subsection"], as they would be read into a config struct: values are collected from
+// all sections with the same name in order, and a blank field resets them, so only the values
+// after the last blank field are returned. Returns nil if there are no values.
+func (f File) GetAll(section, subsection, name string) []string {
+	var values []string
+	for _, field := range f.fields(section, subsection, name) {
+		if field.Blank {
+			values = nil
+		} else {
+			values = append(values, unquotedValue(field))
+		}
+	}
+	return values
+}
+
+// Has reports whether the section [section "subsection"] has a field called name.
+func (f File) Has(section, subsection, name string) bool {
+	return len(f.fields(section, subsection, name)) > 0
+}
+
+// Subsections returns the names of the subsections of the section called name, in the order they
+// first appear, without duplicates. If there is a heading for the section without a subsection,
+// "" is included, as it is read into a map section as a subsection with an empty name.
+//
+// This is the Sections(name) query that goes with Get, GetAll and Has. It is named Subsections
+// because File already has a Sections field, and a method can't have the same name.
+func (f File) Subsections(name string) []string {
+	var subsections []string
+	seen := map[string]bool{}
+	for _, s := range f.Sections {
+		if strings.EqualFold(s.Name, name) && !seen[s.Subsection] {
+			seen[s.Subsection] = true
+			subsections = append(subsections, s.Subsection)
+		}
+	}
+	return subsections
+}
+
+// fields returns the fields called name in all sections [section "subsection"], in order. Field
+// names are matched ignoring case.
+func (f File) fields(section, subsection, name string) []*Field {
+	sectionKey := makeSectionKey(section, subsection)
+	var fields []*Field
+	for _, s := range f.Sections {
+		if s.Key != sectionKey {
+			continue
+		}
+		for _, field := range s.Fields {
+			if strings.EqualFold(field.Name, name) {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
+
+func unquotedValue(field *Field) string {
+	value, err := field.UnquotedValue()
+	if err != nil {
+		return field.Value
+	}
+	return value
+}
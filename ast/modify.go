@@ -2,17 +2,22 @@ package ast
 
 import (
 	"strings"
+
+	"github.com/please-build/gcfg/scanner"
 )
 
 // InjectField injects a field into the AST and returns the modified file. If multiple sections
-// exist with the same name, the field is inserted into the first one found.
+// exist with the same name, the field is inserted into the first one found. fieldValue is
+// written as is, so it must already be quoted and escaped as needed; see NewField.
 func InjectField(f File, fieldName, fieldValue, sectionName, subsectionName string, repeatable bool) File {
-	sectionKey := getKeyFromSectionAndSubsection(sectionName, subsectionName)
+	sectionKey := makeSectionKey(sectionName, subsectionName)
 	fi := makeField(fieldName, fieldValue)
+	fi.marker = f.commentMarker()
 
 	// If file is empty, we can insert the section and field without any further checks
 	if len(f.Sections) == 0 {
 		s := makeSection(sectionName, subsectionName)
+		s.marker = fi.marker
 		s.Fields = append(s.Fields, &fi)
 
 		// Move file's comments to this section's CommentsBefore
@@ -42,6 +47,7 @@ func InjectField(f File, fieldName, fieldValue, sectionName, subsectionName stri
 	}
 
 	s := makeSection(sectionName, subsectionName)
+	s.marker = fi.marker
 
 	// Append blank line if file does not end with blank line
 	if len(f.CommentsAfter) == 0 {
@@ -56,7 +62,7 @@ func InjectField(f File, fieldName, fieldValue, sectionName, subsectionName stri
 // MakeNewSection writes a new section heading to the file. Returns !ok if section already exists.
 func MakeNewSection(f File, sectionName, subsectionName string) (File, bool) {
 	ok := true
-	sectionKey := getKeyFromSectionAndSubsection(sectionName, subsectionName)
+	sectionKey := makeSectionKey(sectionName, subsectionName)
 	for _, s := range f.Sections {
 		if s.Key == sectionKey {
 			ok = false
@@ -65,6 +71,7 @@ func MakeNewSection(f File, sectionName, subsectionName string) (File, bool) {
 
 	if ok {
 		newSection := makeSection(sectionName, subsectionName)
+		newSection.marker = f.commentMarker()
 		f.Sections = append(f.Sections, &newSection)
 	}
 
@@ -79,7 +86,7 @@ func AppendBlankLineToFile(f File) File {
 
 // AppendBlankLineToSection appends an empty to a section
 func AppendBlankLineToSection(f File, sectionName, subsectionName string) (File, bool) {
-	sectionKey := getKeyFromSectionAndSubsection(sectionName, subsectionName)
+	sectionKey := makeSectionKey(sectionName, subsectionName)
 	ok := false
 
 	// If section exists, add empty line to top of next section, unless it's the last
@@ -101,8 +108,9 @@ func AppendBlankLineToSection(f File, sectionName, subsectionName string) (File,
 // AppendFieldToSection appends a field to a section with no knowledge of whether the field is
 // repeatable or not. Creates a new section if section does not exist
 func AppendFieldToSection(f File, fieldName, fieldValue, sectionName, subsectionName string) File {
-	sectionKey := getKeyFromSectionAndSubsection(sectionName, subsectionName)
+	sectionKey := makeSectionKey(sectionName, subsectionName)
 	field := makeField(fieldName, fieldValue)
+	field.marker = f.commentMarker()
 	for i, s := range f.Sections {
 		if s.Key == sectionKey {
 			f.Sections[i].Fields = append(f.Sections[i].Fields, &field)
@@ -111,6 +119,7 @@ func AppendFieldToSection(f File, fieldName, fieldValue, sectionName, subsection
 	}
 
 	s := makeSection(sectionName, subsectionName)
+	s.marker = field.marker
 
 	// Append blank line if file does not end with blank line
 	if len(f.CommentsAfter) == 0 {
@@ -122,9 +131,269 @@ func AppendFieldToSection(f File, fieldName, fieldValue, sectionName, subsection
 	return f
 }
 
+// InsertFieldBefore inserts field into the section of the existing field, just before it.
+// Returns !ok if existing isn't in the file.
+//
+// The doc comment of existing stays with it, while any blank lines and other comments before that
+// now come before field and its own comments.
+func InsertFieldBefore(f File, existing, field *Field) (File, bool) {
+	return insertField(f, existing, field, false)
+}
+
+// InsertFieldAfter inserts field into the section of the existing field, just after it.
+// Returns !ok if existing isn't in the file.
+func InsertFieldAfter(f File, existing, field *Field) (File, bool) {
+	return insertField(f, existing, field, true)
+}
+
+func insertField(f File, existing, field *Field, after bool) (File, bool) {
+	if field.marker == "" {
+		field.marker = f.commentMarker()
+	}
+	insert := func(fields []*Field) ([]*Field, bool) {
+		for i, k := range fields {
+			if k != existing {
+				continue
+			}
+			if after {
+				i++
+			} else {
+				start := docStart(existing.CommentsBefore)
+				field.CommentsBefore = append(existing.CommentsBefore[:start:start], field.CommentsBefore...)
+				existing.CommentsBefore = existing.CommentsBefore[start:]
+			}
+			return append(fields[:i], append([]*Field{field}, fields[i:]...)...), true
+		}
+		return fields, false
+	}
+
+	var ok bool
+	if f.Fields, ok = insert(f.Fields); ok {
+		return f, true
+	}
+	for _, s := range f.Sections {
+		if s.Fields, ok = insert(s.Fields); ok {
+			return f, true
+		}
+	}
+	return f, false
+}
+
+// InsertSectionBefore inserts section into the file just before the existing section. Returns
+// !ok if existing isn't in the file.
+//
+// The doc comment of existing stays with it, while any blank lines and other comments before that
+// now come before section and its own comments. A blank line is added between section and
+// existing.
+func InsertSectionBefore(f File, existing, section *Section) (File, bool) {
+	for i, s := range f.Sections {
+		if s != existing {
+			continue
+		}
+		if section.marker == "" {
+			section.marker = f.commentMarker()
+		}
+		start := docStart(existing.CommentsBefore)
+		section.CommentsBefore = append(existing.CommentsBefore[:start:start], section.CommentsBefore...)
+		existing.CommentsBefore = append([]*Comment{{}}, existing.CommentsBefore[start:]...)
+		f.Sections = append(f.Sections[:i], append([]*Section{section}, f.Sections[i:]...)...)
+		return f, true
+	}
+	return f, false
+}
+
+// InsertSectionAfter inserts section into the file just after the existing section and its
+// fields. Returns !ok if existing isn't in the file. A blank line is added before section unless
+// it already starts with one.
+func InsertSectionAfter(f File, existing, section *Section) (File, bool) {
+	for i, s := range f.Sections {
+		if s != existing {
+			continue
+		}
+		if section.marker == "" {
+			section.marker = f.commentMarker()
+		}
+		if len(section.CommentsBefore) == 0 || strings.TrimSpace(section.CommentsBefore[0].Str) != "" {
+			section.CommentsBefore = append([]*Comment{{}}, section.CommentsBefore...)
+		}
+		f.Sections = append(f.Sections[:i+1], append([]*Section{section}, f.Sections[i+1:]...)...)
+		return f, true
+	}
+	return f, false
+}
+
+// NewSection returns a new section with the given name and subsection, which may be empty.
+func NewSection(name, subsection string) *Section {
+	s := makeSection(name, subsection)
+	return &s
+}
+
+// RenameSection renames all sections [oldName "oldSubsection"] to [newName "newSubsection"],
+// keeping their comments and the formatting of their headings. If a section with the new name
+// already exists, the fields of the renamed sections are moved to the end of it instead, along
+// with the comments before their headings and their headings' trailing comments, which become
+// comment lines. Returns !ok if no section was renamed, or if newName isn't a valid section name
+// or newSubsection has a line break or NUL character.
+func RenameSection(f File, oldName, oldSubsection, newName, newSubsection string) (File, bool) {
+	if !scanner.IsIdentifier(newName) || strings.ContainsAny(newSubsection, "\n\r\x00") {
+		return f, false
+	}
+	oldKey, newKey := makeSectionKey(oldName, oldSubsection), makeSectionKey(newName, newSubsection)
+	target := f.MaybeGetSection(newName, newSubsection)
+	if target != nil && oldKey == newKey {
+		target = nil // only the spelling changes
+	}
+
+	ok := false
+	for i := 0; i < len(f.Sections); i++ {
+		s := f.Sections[i]
+		if s.Key != oldKey {
+			continue
+		}
+		ok = true
+		if target == nil {
+			s.HeadingStr = renameHeading(s.getHeadingStr(), newName, newSubsection)
+			s.Name, s.Subsection, s.Key = newName, newSubsection, newKey
+			continue
+		}
+
+		// Merge into the target, dropping the blank lines that separated the heading from
+		// whatever came before it. The heading's trailing comment becomes a comment line.
+		comments := s.CommentsBefore
+		for len(comments) > 0 && strings.TrimSpace(comments[0].Str) == "" {
+			comments = comments[1:]
+		}
+		if s.TrailingComment != "" {
+			comments = append(comments[:len(comments):len(comments)], &Comment{Str: indentation(s.getHeadingStr()) + s.TrailingComment})
+		}
+		if len(s.Fields) > 0 {
+			s.Fields[0].CommentsBefore = append(comments[:len(comments):len(comments)], s.Fields[0].CommentsBefore...)
+		} else {
+			giveComments(&f, i, len(s.Fields)-1, comments)
+		}
+		target.Fields = append(target.Fields, s.Fields...)
+		f.Sections = append(f.Sections[:i], f.Sections[i+1:]...)
+		i--
+	}
+	return f, ok
+}
+
+// RenameField renames all fields called oldName in the section [sectionName "subsectionName"] to
+// newName, keeping their values, comments and formatting. Field names are matched ignoring case.
+// Returns !ok if no field was renamed, or if newName isn't a valid variable name.
+func RenameField(f File, sectionName, subsectionName, oldName, newName string) (File, bool) {
+	if !scanner.IsIdentifier(newName) {
+		return f, false
+	}
+	sectionKey := makeSectionKey(sectionName, subsectionName)
+	ok := false
+	for _, s := range f.Sections {
+		if s.Key != sectionKey {
+			continue
+		}
+		for _, field := range s.Fields {
+			if !strings.EqualFold(field.Name, oldName) {
+				continue
+			}
+			ok = true
+			str := field.getStr()
+			indent := indentation(str)
+			field.Str = indent + newName + str[len(indent)+len(field.Name):]
+			field.Name = newName
+		}
+	}
+	return f, ok
+}
+
+// MoveField moves all fields called fieldName from the section [fromSection "fromSubsection"] to
+// the end of the section [toSection "toSubsection"], keeping their values, doc comments, trailing
+// comments and formatting. Other comments and blank lines before the fields stay where they
+// were. Field names are matched ignoring case. The target section is created if it doesn't
+// exist. Returns !ok if no field was moved.
+func MoveField(f File, fromSection, fromSubsection, toSection, toSubsection, fieldName string) (File, bool) {
+	fromKey := makeSectionKey(fromSection, fromSubsection)
+	if fromKey == makeSectionKey(toSection, toSubsection) {
+		return f, false
+	}
+
+	var moved []*Field
+	for i, s := range f.Sections {
+		if s.Key != fromKey {
+			continue
+		}
+		for j := 0; j < len(s.Fields); j++ {
+			field := s.Fields[j]
+			if !strings.EqualFold(field.Name, fieldName) {
+				continue
+			}
+			start := docStart(field.CommentsBefore)
+			giveComments(&f, i, j, field.CommentsBefore[:start])
+			field.CommentsBefore = field.CommentsBefore[start:]
+			s.Fields = append(s.Fields[:j], s.Fields[j+1:]...)
+			j--
+			moved = append(moved, field)
+		}
+	}
+	if len(moved) == 0 {
+		return f, false
+	}
+
+	target := f.MaybeGetSection(toSection, toSubsection)
+	if target == nil {
+		newSection := makeSection(toSection, toSubsection)
+		newSection.marker = f.commentMarker()
+		newSection.CommentsBefore = []*Comment{{}}
+		target = &newSection
+		f.Sections = append(f.Sections, target)
+	}
+	target.Fields = append(target.Fields, moved...)
+	return f, true
+}
+
+// giveComments adds comments to the start of the comments of whatever follows the field at
+// index fieldIndex in the section at index sectionIndex.
+func giveComments(f *File, sectionIndex, fieldIndex int, comments []*Comment) {
+	if len(comments) == 0 {
+		return
+	}
+	s := f.Sections[sectionIndex]
+	switch {
+	case fieldIndex+1 < len(s.Fields):
+		next := s.Fields[fieldIndex+1]
+		next.CommentsBefore = append(comments[:len(comments):len(comments)], next.CommentsBefore...)
+	case sectionIndex+1 < len(f.Sections):
+		next := f.Sections[sectionIndex+1]
+		next.CommentsBefore = append(comments[:len(comments):len(comments)], next.CommentsBefore...)
+	default:
+		f.CommentsAfter = append(comments[:len(comments):len(comments)], f.CommentsAfter...)
+	}
+}
+
+// renameHeading returns the section heading str with its name and subsection replaced, keeping
+// its indentation, spacing and trailing comment.
+func renameHeading(str, name, subsection string) string {
+	open := strings.Index(str, "[")
+	end := trailingCommentStart(str)
+	if end < 0 {
+		end = len(str)
+	}
+	close := strings.LastIndex(str[:end], "]")
+	if open < 0 || close < open {
+		return makeSection(name, subsection).HeadingStr
+	}
+
+	inner := str[open+1 : close]
+	heading := name
+	if subsection != "" {
+		heading += " \"" + subsectionEscape.Replace(subsection) + "\""
+	}
+	heading = indentation(inner) + heading + inner[len(strings.TrimRight(inner, " \t")):]
+	return str[:open+1] + heading + str[close:]
+}
+
 // DeleteAllFieldsWithName deletes all fields with the name fieldName in section [sectionName "subsectionName"]
 func DeleteAllFieldsWithName(f File, fieldName, sectionName, subsectionName string) File {
-	sectionKey := getKeyFromSectionAndSubsection(sectionName, subsectionName)
+	sectionKey := makeSectionKey(sectionName, subsectionName)
 	for i, s := range f.Sections {
 		if s.Key == sectionKey {
 			for j := 0; j < len(s.Fields); j++ {
@@ -144,7 +413,7 @@ func DeleteAllFieldsWithName(f File, fieldName, sectionName, subsectionName stri
 
 // DeleteFieldWithValue deletes a field from a section if the name and value are fieldName and fieldValue
 func DeleteFieldWithValue(f File, fieldName, fieldValue, sectionName, subsectionName string) File {
-	sectionKey := getKeyFromSectionAndSubsection(sectionName, subsectionName)
+	sectionKey := makeSectionKey(sectionName, subsectionName)
 	for i, s := range f.Sections {
 		if s.Key == sectionKey {
 			for j := 0; j < len(s.Fields); j++ {
@@ -217,15 +486,20 @@ func makeSection(sect, subsection string) Section {
 		Subsection: subsection,
 		Key:        makeSectionKey(sect, subsection)}
 
-	if subsection == "" {
-		s.HeadingStr = "[" + sect + "]"
-	} else {
-		s.HeadingStr = "[" + sect + " \"" + subsection + "\"]"
-	}
+	s.HeadingStr = s.getHeadingStr()
 
 	return s
 }
 
+// NewField returns a new field with the given name and value. Unlike the
+// values passed to InjectField and AppendFieldToSection, value is quoted and
+// escaped as needed, as by Field.SetValue.
+func NewField(name, value string) *Field {
+	f := &Field{Name: name}
+	f.SetValue(value)
+	return f
+}
+
 // makeField initialises an ast field given a key and a value
 func makeField(name, value string) Field {
 	return Field{
@@ -246,14 +520,6 @@ func replaceFieldValue(field *Field, value string) Field {
 		Value:           value,
 		TrailingComment: field.TrailingComment,
 		CommentsBefore:  field.CommentsBefore,
+		marker:          field.marker,
 	}
 }
-
-// getSectionKeyFromString strips brackets from a section header and lowers it.
-// E.g. '[Something "sub"]' -> 'something&sub'
-func getKeyFromSectionAndSubsection(sect, subsection string) string {
-	if subsection == "" {
-		return strings.ToLower(sect)
-	}
-	return strings.ToLower(sect) + "&" + strings.ToLower(subsection)
-}
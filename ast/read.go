@@ -1,107 +1,267 @@
 package ast
 
 import (
-	"bufio"
+	"bytes"
 	"io"
 	"log"
-	"regexp"
+	"os"
 	"strings"
+
+	"github.com/please-build/gcfg/scanner"
+	"github.com/please-build/gcfg/token"
 )
 
-func Read(file io.Reader) File {
-	var f File
-	scanner := bufio.NewScanner(file)
-	commentBuffer := make([]*Comment, 0, 64)
-	fieldBuffer := make([]*Field, 0, 64)
-	fieldRegex := regexp.MustCompile(`^ *[a-zA-Z0-9_.-]+ *= *`)
-	sectionRegex := regexp.MustCompile(`^ *\[[a-zA-Z0-9_" .-]+\]`)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if len(strings.TrimSpace(line)) == 0 { // Blank line
-			commentBuffer = append(commentBuffer, &Comment{})
-
-		} else if l := strings.TrimSpace(line); strings.HasPrefix(l, ";") || strings.HasPrefix(l, "#") { // Comment
-			commentBuffer = append(commentBuffer, &Comment{Str: line})
-		} else if fieldRegex.MatchString(line) { // Field
-			if field, ok := tryMakeFieldFromString(line); ok {
-				// Attach comment buffer
-				field.CommentsBefore = commentBuffer
-				commentBuffer = nil
-				fieldBuffer = append(fieldBuffer, field)
-			} else {
-				log.Panicf("could not parse field '%v'. Check the field name does not contain spaces. If the field value contains spaces, make sure to enclose the string in double quotes.", line)
+var utf8Bom = []byte("\ufeff")
+
+// Read reads gcfg formatted data into a File. Anything that gcfg.ReadInto
+// accepts can be read, and writing the File back without modifying it
+// reproduces the data byte for byte. Unlike gcfg.ReadInto, fields before the
+// first section heading are allowed; they are stored in File.Fields.
+//
+// Syntax errors are returned as a scanner.ErrorList.
+func Read(file io.Reader) (File, error) {
+	src, err := io.ReadAll(file)
+	if err != nil {
+		return File{}, err
+	}
+	return parse("", src)
+}
+
+// ReadFile reads the file filename into a File, like Read. Positions in the
+// File and in errors include the file name.
+func ReadFile(filename string) (File, error) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return File{}, err
+	}
+	return parse(filename, src)
+}
+
+// MustRead is like Read, but panics if the data can't be read.
+//
+// Deprecated: use Read, which returns an error instead.
+func MustRead(file io.Reader) File {
+	f, err := Read(file)
+	if err != nil {
+		log.Panicf("could not read config: %v", err)
+	}
+	return f
+}
+
+// line is the tokens of a single line of gcfg data, which may span several
+// lines of text if it has a value with continuation lines.
+type line struct {
+	str    string    // the text of the line, without its line terminator
+	pos    token.Pos // the position of the start of the line
+	eol    token.Pos // the position of the line terminator
+	tokens []item
+}
+
+type item struct {
+	pos token.Pos
+	tok token.Token
+	lit string
+}
+
+// parse parses src, read from the file filename, into a File.
+func parse(filename string, src []byte) (File, error) {
+	f := File{FileSet: token.NewFileSet()}
+	if bytes.HasPrefix(src, utf8Bom) {
+		f.bom = true
+		src = src[len(utf8Bom):]
+	}
+
+	fset := f.FileSet
+	file := fset.AddFile(filename, fset.Base(), len(src))
+	var s scanner.Scanner
+	var errs scanner.ErrorList
+	s.Init(file, src, func(p token.Position, m string) { errs.Add(p, m) }, scanner.ScanComments)
+
+	var comments []*Comment
+	var section *Section
+	// Each node records the comment marker of the nearest comment before it,
+	// or the first one in the file, so that comments added later match.
+	marker, firstMarker := "", ""
+	var unmarked []*string
+	setMarker := func(node *string, trailingComment string) {
+		if *node = marker; marker == "" {
+			unmarked = append(unmarked, node)
+		}
+		if trailingComment != "" {
+			marker = trailingComment[:1]
+		}
+		if firstMarker == "" {
+			firstMarker = marker
+		}
+	}
+	for start := 0; ; {
+		l := line{}
+		pos, tok, lit := s.Scan()
+		for tok != token.EOL && tok != token.EOF {
+			l.tokens = append(l.tokens, item{pos, tok, lit})
+			pos, tok, lit = s.Scan()
+		}
+		end := file.Offset(pos)
+		if tok == token.EOF && start == end {
+			break
+		}
+		l.str, l.pos, l.eol = string(src[start:end]), file.Pos(start), pos
+		if end > start && src[end-1] == '\r' {
+			l.eol = file.Pos(end - 1) // the CR of a CRLF line ending
+		}
+		if errs.Len() > 0 {
+			return File{}, errs.Err()
+		}
+
+		switch first := l.next(); first.tok {
+		case token.EOF, token.COMMENT:
+			if l.next().tok != token.EOF {
+				return File{}, errorAt(fset, first.pos, "expected EOL")
 			}
-		} else if sectionRegex.MatchString(line) { // Section
-			if section, ok := tryMakeSectionFromString(line); ok {
-				if len(f.Sections) > 0 {
-					f.Sections[len(f.Sections)-1].Fields = fieldBuffer
-				} else if len(f.Sections) == 0 && len(fieldBuffer) > 0 {
-					f.Fields = fieldBuffer
-					fieldBuffer = nil
+			comments = append(comments, &Comment{Str: l.str, Pos: l.pos, End: l.eol})
+			if _, m, _, ok := commentText(l.str); ok {
+				marker = m
+				if firstMarker == "" {
+					firstMarker = m
 				}
-				fieldBuffer = nil
-				section.CommentsBefore = commentBuffer
-				commentBuffer = nil
-				f.Sections = append(f.Sections, &section)
 			}
-		} else { // Unrecognised
-			log.Panicf("did not recognise line %v", line)
+		case token.LBRACK:
+			s, err := l.section(fset)
+			if err != nil {
+				return File{}, err
+			}
+			s.CommentsBefore, comments = comments, nil
+			setMarker(&s.marker, s.TrailingComment)
+			section = s
+			f.Sections = append(f.Sections, s)
+		case token.IDENT:
+			field, err := l.field(fset, first.lit)
+			if err != nil {
+				return File{}, err
+			}
+			field.CommentsBefore, comments = comments, nil
+			setMarker(&field.marker, field.TrailingComment)
+			if section != nil {
+				section.Fields = append(section.Fields, field)
+			} else {
+				f.Fields = append(f.Fields, field)
+			}
+		default:
+			return File{}, errorAt(fset, first.pos, "expected section header or variable declaration")
 		}
-	}
 
-	// Deal with fields and comments still left in the buffers
-	if len(fieldBuffer) > 0 && len(f.Sections) > 0 {
-		f.Sections[len(f.Sections)-1].Fields = fieldBuffer
-		fieldBuffer = nil
-	} else if len(fieldBuffer) > 0 && len(f.Sections) == 0 {
-		f.Fields = fieldBuffer
-		fieldBuffer = nil
+		if tok == token.EOF {
+			f.noFinalNewline = true
+			break
+		}
+		start = end + 1
 	}
-
-	if len(commentBuffer) > 0 {
-		f.CommentsAfter = commentBuffer
-		commentBuffer = nil
+	f.CommentsAfter = comments
+	for _, node := range unmarked {
+		*node = firstMarker
 	}
+	return f, nil
+}
 
-	return f
+// errorAt returns an error with the position pos, as a scanner.ErrorList.
+func errorAt(fset *token.FileSet, pos token.Pos, msg string) error {
+	var errs scanner.ErrorList
+	errs.Add(fset.Position(pos), msg)
+	return errs.Err()
 }
 
-// tryMakeFieldFromString takes a field string and tries to return an AST field
-func tryMakeFieldFromString(s string) (*Field, bool) {
-	reg := regexp.MustCompile(`^ *([a-zA-Z0-9_.-]+) *= *([a-zA-Z0-9_@{}&,|"~<>/:= +.-]+)?( *;.*)?`)
-	matches := reg.FindStringSubmatch(s)
-	if len(matches) == 0 {
-		return nil, false
+// peek returns the next token of l, or an EOF token at the end of the line if
+// there are no more.
+func (l *line) peek() item {
+	if len(l.tokens) == 0 {
+		return item{pos: l.eol, tok: token.EOF}
 	}
+	return l.tokens[0]
+}
 
-	return &Field{
-		Str:             s,
-		Name:            matches[1],
-		Value:           matches[2],
-		TrailingComment: matches[3]}, true
+// next removes the next token from l and returns it.
+func (l *line) next() item {
+	i := l.peek()
+	if len(l.tokens) > 0 {
+		l.tokens = l.tokens[1:]
+	}
+	return i
 }
 
-// tryMakeSectionFromString takes a string tries to make a Section
-func tryMakeSectionFromString(line string) (Section, bool) {
-	s := Section{HeadingStr: line}
-	secAndSubReg := regexp.MustCompile(`^ *\[ *[a-zA-Z0-9_.-]+ *" *[a-zA-Z0-9_.-]+ *" *\].*`)
-	secOnlyReg := regexp.MustCompile(`^ *\[ *[a-zA-Z0-9_.-]+ *\].*`)
+// end returns the trailing comment of l, if it has one, after checking that
+// there's nothing else left on it.
+func (l *line) end(fset *token.FileSet) (string, error) {
+	comment := ""
+	i := l.next()
+	if i.tok == token.COMMENT {
+		comment = strings.TrimSuffix(i.lit, "\r")
+		i = l.next()
+	}
+	if i.tok != token.EOF {
+		return "", errorAt(fset, i.pos, "expected EOL, EOF, or comment")
+	}
+	return comment, nil
+}
 
-	if secAndSubReg.MatchString(line) {
-		subsectionReg := regexp.MustCompile(`(?:" *)([a-zA-Z0-9_.-]+)(?: *")`)
-		s.Subsection = strings.ToLower(subsectionReg.FindStringSubmatch(line)[1])
-		sectionReg := regexp.MustCompile(`(?:\[ *)([a-zA-Z0-9_.-]+)(?: *")`)
-		s.Name = strings.ToLower(sectionReg.FindStringSubmatch(line)[1])
-		s.Key = makeSectionKey(s.Name, s.Subsection)
-		return s, true
+// section parses a section heading, after its opening bracket.
+func (l *line) section(fset *token.FileSet) (*Section, error) {
+	i := l.next()
+	if i.tok != token.IDENT {
+		return nil, errorAt(fset, i.pos, "expected section name")
+	}
+	name, subsection := i.lit, ""
+	i = l.next()
+	if i.tok == token.STRING {
+		var err error
+		if subsection, err = scanner.Unquote(i.lit); err != nil {
+			return nil, errorAt(fset, i.pos, err.Error())
+		} else if subsection == "" {
+			return nil, errorAt(fset, i.pos, "empty subsection name")
+		}
+		i = l.next()
+	}
+	if i.tok != token.RBRACK {
+		if subsection == "" {
+			return nil, errorAt(fset, i.pos, "expected subsection name or right bracket")
+		}
+		return nil, errorAt(fset, i.pos, "expected right bracket")
+	}
+	comment, err := l.end(fset)
+	if err != nil {
+		return nil, err
 	}
 
-	if secOnlyReg.MatchString(line) {
-		sectionReg := regexp.MustCompile(`(?:\[ *)([a-zA-Z0-9_.-]+)(?: *\])`)
-		s.Name = strings.ToLower(sectionReg.FindStringSubmatch(line)[1])
-		s.Key = makeSectionKey(s.Name, "")
-		return s, true
+	s := &Section{
+		HeadingStr:      l.str,
+		Pos:             l.pos,
+		End:             l.eol,
+		Name:            name,
+		Subsection:      subsection,
+		TrailingComment: comment,
 	}
+	s.Key = makeSectionKey(s.Name, s.Subsection)
+	return s, nil
+}
 
-	return s, false
+// field parses a field, after its name.
+func (l *line) field(fset *token.FileSet, name string) (*Field, error) {
+	f := &Field{Str: l.str, Name: name, Pos: l.pos, End: l.eol}
+	switch i := l.peek(); i.tok {
+	case token.ASSIGN:
+		l.next()
+		i = l.next()
+		if i.tok != token.STRING {
+			return nil, errorAt(fset, i.pos, "expected value")
+		}
+		f.Value = i.lit
+	case token.COMMENT, token.EOF:
+		f.Blank = true
+	default:
+		return nil, errorAt(fset, i.pos, "expected '='")
+	}
+	var err error
+	if f.TrailingComment, err = l.end(fset); err != nil {
+		return nil, err
+	}
+	return f, nil
 }
@@ -1,61 +1,144 @@
 package ast
 
 import (
-	"log"
+	"bytes"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 )
 
-// Write writes an AST file to a file on disk.
+// Write writes an AST file to a file on disk, as File.WriteFile does.
 func Write(f File, output string) error {
-	data := convertASTToBytes(f)
-	if err := os.WriteFile(output, []byte(data), 0644); err != nil {
+	return f.WriteFile(output)
+}
+
+// WriteTo writes the file in gcfg format to w.
+func (f File) WriteTo(w io.Writer) (int64, error) {
+	data, err := convertASTToBytes(f)
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// WriteFile writes the file in gcfg format to the file filename, atomically:
+// the data is written to a temporary file in the same directory, which is
+// synced to disk and then renamed to filename, so filename is never left
+// partially written. If filename already exists, its permissions are kept,
+// and if it is a symbolic link, the file it links to is replaced. Otherwise
+// the file is created with mode 0644.
+func (f File) WriteFile(filename string) error {
+	data, err := convertASTToBytes(f)
+	if err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode().Perm()
+		if filename, err = filepath.EvalSymlinks(filename); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if err := writeAndSync(tmp, data, mode); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		os.Remove(tmp.Name())
 		return err
 	}
 	return nil
 }
 
+// writeAndSync writes data to the new file tmp with the permissions mode, and
+// syncs and closes it.
+func writeAndSync(tmp *os.File, data []byte, mode os.FileMode) error {
+	_, err := tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(mode)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
 // convertASTToBytes converts an AST file to a byte slice.
-func convertASTToBytes(f File) []byte {
+func convertASTToBytes(f File) ([]byte, error) {
 	var data []byte
+	if f.bom {
+		data = append(data, utf8Bom...)
+	}
 	for _, field := range f.Fields {
-		data = append(data, field.toBytes()...)
+		b, err := field.toBytes()
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, b...)
 	}
 	for _, section := range f.Sections {
-		data = append(data, section.toBytes()...)
+		b, err := section.toBytes()
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, b...)
 		for _, field := range section.Fields {
-			data = append(data, field.toBytes()...)
+			b, err := field.toBytes()
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, b...)
 		}
 	}
 	for _, comment := range f.CommentsAfter {
 		data = append(data, comment.toBytes()...)
 	}
+	if f.noFinalNewline {
+		data = bytes.TrimSuffix(data, []byte("\n"))
+	}
 
-	return data
+	return data, nil
 }
 
 // toBytes returns a correctly formatted section header as a byte slice.
 // Needed for writing to output file.
-func (s Section) toBytes() []byte {
-	if s.Name == "" {
-		log.Fatalf("Tried to convert an empty section to byte slice")
+func (s Section) toBytes() ([]byte, error) {
+	if s.Name == "" && s.HeadingStr == "" {
+		return nil, fmt.Errorf("cannot write a section without a name")
 	}
 	var ret string
 	for _, c := range s.CommentsBefore {
 		ret += c.Str + "\n"
 	}
 	ret += s.getHeadingStr() + "\n"
-	return []byte(ret)
+	return []byte(ret), nil
 }
 
 // toBytes returns a field as a byte slice. Needed for writing
 // to output file.
-func (f Field) toBytes() []byte {
+func (f Field) toBytes() ([]byte, error) {
+	if f.Name == "" && f.Str == "" {
+		return nil, fmt.Errorf("cannot write a field without a name")
+	}
 	var ret string
 	for _, c := range f.CommentsBefore {
 		ret += c.Str + "\n"
 	}
 	ret += f.getStr() + "\n"
-	return []byte(ret)
+	return []byte(ret), nil
 }
 
 func (c Comment) toBytes() []byte {
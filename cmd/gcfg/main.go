@@ -0,0 +1,161 @@
+// Command gcfg works with gcfg files.
+//
+// Usage:
+//
+//	gcfg convert [-from format] [-to format] [-strict] [-o file] [file]
+//
+// The convert subcommand converts file, or stdin if no file is given, between
+// the gcfg, toml and yaml formats, as described in the documentation of
+// package github.com/please-build/gcfg/convert. The input format defaults to
+// the one given by the file's extension, or gcfg, and the output format to the
+// one given by the output file's extension.
+//
+// Data that can't be represented in the output format is reported on stderr.
+// With -strict, this is an error.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/please-build/gcfg"
+	"github.com/please-build/gcfg/convert"
+	"gopkg.in/warnings.v0"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: gcfg convert [-from format] [-to format] [-strict] [-o file] [file]\n")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "convert" {
+		usage()
+	}
+
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	from := flags.String("from", "", "input format: gcfg, toml or yaml (default from the file extension)")
+	to := flags.String("to", "", "output format: gcfg, toml or yaml (default from the output file extension)")
+	strict := flags.Bool("strict", false, "fail if the conversion is lossy")
+	output := flags.String("o", "", "output file (default stdout)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: gcfg convert [flags] [file]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[2:])
+	if flags.NArg() > 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flags.Arg(0), *output, *from, *to, *strict); err != nil {
+		fmt.Fprintf(os.Stderr, "gcfg: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(input, output, from, to string, strict bool) error {
+	if from == "" {
+		from = formatOf(input)
+	}
+	if to == "" {
+		if output == "" {
+			return fmt.Errorf("-to is required when writing to stdout")
+		}
+		to = formatOf(output)
+	}
+
+	var data []byte
+	var err error
+	if input == "" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(input)
+	}
+	if err != nil {
+		return err
+	}
+
+	res, err := convertData(data, from, to)
+	if err := gcfg.FatalOnly(err); err != nil {
+		return err
+	}
+	if list, ok := err.(warnings.List); ok {
+		for _, w := range list.Warnings {
+			fmt.Fprintf(os.Stderr, "gcfg: lossy conversion: %s\n", w)
+		}
+		if strict {
+			return fmt.Errorf("conversion from %s to %s is lossy", from, to)
+		}
+	}
+
+	if output == "" {
+		_, err = os.Stdout.Write(res)
+		return err
+	}
+	return ioutil.WriteFile(output, res, 0644)
+}
+
+// convertData converts data from one format to another. Any lossy conversions
+// are reported as warnings in the returned error.
+func convertData(data []byte, from, to string) ([]byte, error) {
+	var d *gcfg.Document
+	var lossy []error
+	var err error
+	switch from {
+	case "gcfg":
+		d, err = gcfg.ReadDocument(bytes.NewReader(data))
+	case "toml":
+		d, err = convert.FromTOML(data)
+	case "yaml":
+		d, err = convert.FromYAML(data)
+	default:
+		return nil, fmt.Errorf("unknown input format %q", from)
+	}
+	if err := gcfg.FatalOnly(err); err != nil {
+		return nil, err
+	}
+	if list, ok := err.(warnings.List); ok {
+		lossy = list.Warnings
+	}
+
+	var res []byte
+	switch to {
+	case "gcfg":
+		var b bytes.Buffer
+		_, err = d.WriteTo(&b)
+		res = b.Bytes()
+	case "toml":
+		res, err = convert.ToTOML(d)
+	case "yaml":
+		res, err = convert.ToYAML(d)
+	default:
+		return nil, fmt.Errorf("unknown output format %q", to)
+	}
+	if err := gcfg.FatalOnly(err); err != nil {
+		return nil, err
+	}
+	if list, ok := err.(warnings.List); ok {
+		lossy = append(lossy, list.Warnings...)
+	}
+
+	if len(lossy) > 0 {
+		return res, warnings.List{Warnings: lossy}
+	}
+	return res, nil
+}
+
+// formatOf returns the format of a file, given by its extension.
+func formatOf(filename string) string {
+	switch filepath.Ext(filename) {
+	case ".toml":
+		return "toml"
+	case ".yaml", ".yml":
+		return "yaml"
+	}
+	return "gcfg"
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/please-build/gcfg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertData(t *testing.T) {
+	res, err := convertData([]byte("[section]\nname = value\n"), "gcfg", "toml")
+	require.NoError(t, err)
+	assert.Equal(t, "[section]\nname = \"value\"\n", string(res))
+
+	res, err = convertData(res, "toml", "gcfg")
+	require.NoError(t, err)
+	assert.Equal(t, "[section]\nname = value\n", string(res))
+
+	res, err = convertData([]byte("section:\n  name: null\n  other: x\n"), "yaml", "gcfg")
+	assert.Error(t, err)
+	assert.NoError(t, gcfg.FatalOnly(err))
+	assert.Equal(t, "[section]\nother = x\n", string(res))
+
+	_, err = convertData([]byte("[section"), "gcfg", "yaml")
+	assert.Error(t, gcfg.FatalOnly(err))
+
+	_, err = convertData(nil, "ini", "yaml")
+	assert.Error(t, err)
+	_, err = convertData(nil, "gcfg", "json")
+	assert.Error(t, err)
+}
+
+func TestFormatOf(t *testing.T) {
+	assert.Equal(t, "toml", formatOf("config.toml"))
+	assert.Equal(t, "yaml", formatOf("config.yml"))
+	assert.Equal(t, "gcfg", formatOf("config.ini"))
+	assert.Equal(t, "gcfg", formatOf(""))
+}
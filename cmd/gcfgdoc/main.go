@@ -0,0 +1,168 @@
+// Command gcfgdoc generates reference documentation for a gcfg config struct
+// type.
+//
+// Usage:
+//
+//	gcfgdoc [-format markdown|html|sample] [-defaults func] [-o file] package type
+//
+// The markdown and html formats list each section and variable with its type,
+// default, allowed values and the description given by its `help` tag. The
+// sample format is an annotated gcfg file with every variable commented out
+// and set to its default.
+//
+// Defaults are taken from the value returned by the function named by the
+// -defaults flag, which must be declared in the same package as the type and
+// take no arguments.
+//
+// gcfgdoc must be run from within a module that requires both the package
+// containing the type and github.com/please-build/gcfg. It writes a small
+// program describing the type to a temporary directory in the current
+// directory, and runs it with "go run".
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"text/template"
+)
+
+var program = template.Must(template.New("program").Parse(`package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+
+	"github.com/please-build/gcfg"
+
+	config {{printf "%q" .Package}}
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	s, err := gcfg.SchemaOf(reflect.TypeOf(config.{{.Type}}{}))
+	if err != nil {
+		return err
+	}
+{{- if .Defaults}}
+	defaults := reflect.ValueOf(config.{{.Defaults}}())
+	if defaults.Kind() != reflect.Ptr {
+		p := reflect.New(defaults.Type())
+		p.Elem().Set(defaults)
+		defaults = p
+	}
+	if err := s.DefaultsFrom(defaults.Interface()); err != nil {
+		return err
+	}
+{{- end}}
+{{- if eq .Format "sample"}}
+	return s.WriteSample(os.Stdout)
+{{- else if eq .Format "html"}}
+	return s.WriteReference(os.Stdout, gcfg.HTML)
+{{- else}}
+	return s.WriteReference(os.Stdout, gcfg.Markdown)
+{{- end}}
+}
+`))
+
+type options struct {
+	Package  string
+	Type     string
+	Defaults string
+	Format   string
+}
+
+func main() {
+	var opts options
+	var output string
+	flag.StringVar(&opts.Format, "format", "markdown", "output format: markdown, html or sample")
+	flag.StringVar(&opts.Defaults, "defaults", "", "name of a function in the package returning the default config")
+	flag.StringVar(&output, "o", "", "output file (default stdout)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: gcfgdoc [flags] package type\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	opts.Package, opts.Type = flag.Arg(0), flag.Arg(1)
+
+	if err := run(opts, output); err != nil {
+		fmt.Fprintf(os.Stderr, "gcfgdoc: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(opts options, output string) error {
+	src, err := generate(opts)
+	if err != nil {
+		return err
+	}
+
+	// The program has to be inside the module for it to be able to import the
+	// package containing the type.
+	dir, err := os.MkdirTemp(".", ".gcfgdoc")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), src, 0644); err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	cmd := exec.Command("go", "run", "./"+filepath.ToSlash(dir))
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to describe %s.%s: %s", opts.Package, opts.Type, err)
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	_, err = out.WriteTo(w)
+	return err
+}
+
+// generate returns the source of a program writing the documentation
+// described by opts to stdout.
+func generate(opts options) ([]byte, error) {
+	switch opts.Format {
+	case "markdown", "html", "sample":
+	default:
+		return nil, fmt.Errorf("unknown format %q", opts.Format)
+	}
+	if !token.IsIdentifier(opts.Type) || !token.IsExported(opts.Type) {
+		return nil, fmt.Errorf("invalid type name %q", opts.Type)
+	}
+	if opts.Defaults != "" && (!token.IsIdentifier(opts.Defaults) || !token.IsExported(opts.Defaults)) {
+		return nil, fmt.Errorf("invalid function name %q", opts.Defaults)
+	}
+
+	var b bytes.Buffer
+	if err := program.Execute(&b, opts); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
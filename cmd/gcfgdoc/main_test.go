@@ -0,0 +1,40 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	for _, format := range []string{"markdown", "html", "sample"} {
+		src, err := generate(options{
+			Package:  "github.com/example/config",
+			Type:     "Configuration",
+			Defaults: "DefaultConfiguration",
+			Format:   format,
+		})
+		require.NoError(t, err)
+		_, err = parser.ParseFile(token.NewFileSet(), "main.go", src, 0)
+		assert.NoError(t, err, string(src))
+		assert.Contains(t, string(src), `config "github.com/example/config"`)
+		assert.Contains(t, string(src), "config.DefaultConfiguration()")
+	}
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	_, err := generate(options{Package: "example", Type: "Config", Format: "pdf"})
+	assert.Error(t, err)
+
+	_, err = generate(options{Package: "example", Type: "config", Format: "html"})
+	assert.Error(t, err)
+
+	_, err = generate(options{Package: "example", Type: "Config{}); os.Exit(", Format: "html"})
+	assert.Error(t, err)
+
+	_, err = generate(options{Package: "example", Type: "Config", Defaults: "x()", Format: "html"})
+	assert.Error(t, err)
+}
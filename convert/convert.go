@@ -0,0 +1,302 @@
+// Package convert converts gcfg documents to and from TOML and YAML.
+//
+// Sections map to tables (TOML) or mappings (YAML) at the top level, and
+// subsections to tables nested in their section, so that
+//
+//	[section]
+//	name = value
+//	[section "sub"]
+//	path = a
+//	path = b
+//
+// converts to the TOML
+//
+//	[section]
+//	name = "value"
+//
+//	[section.sub]
+//	path = ["a", "b"]
+//
+// and to the YAML
+//
+//	section:
+//	  name: value
+//	  sub:
+//	    path:
+//	      - a
+//	      - b
+//
+// A variable that is assigned once becomes a string, and a variable that is
+// assigned several times becomes an array of strings. A blank variable (one
+// given without a value) resets the values assigned before it. If it's the
+// only assignment the variable becomes true, and if it's the last one after
+// earlier values the variable becomes an empty array.
+//
+// In the other direction, booleans and numbers are converted to strings,
+// arrays become repeated variables, and an empty array becomes a blank
+// variable.
+//
+// Comments are never converted. Other data that can't be represented in the
+// target format is dropped, and reported as a *LossyError. This includes tables
+// nested more than two levels deep, arrays of tables or arrays, null values,
+// names that aren't valid gcfg identifiers, keys that only differ in case,
+// which gcfg doesn't distinguish, gcfg subsections with the same name as a
+// variable of their section, and the reset done by a blank variable that is
+// followed by more values. Date and time values are converted to strings, which
+// is also reported. The conversion functions return the converted data along
+// with a warnings list of the LossyErrors, which can be filtered out with
+// gcfg.FatalOnly.
+package convert
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/please-build/gcfg"
+	"gopkg.in/warnings.v0"
+)
+
+// LossyError reports data that was dropped or changed because it can't be
+// represented in the target format.
+type LossyError struct {
+	Path   string // dotted path of the data, e.g. "section.sub.name"
+	Reason string
+}
+
+func (e *LossyError) Error() string {
+	return e.Path + ": " + e.Reason
+}
+
+func isFatal(err error) bool {
+	_, ok := err.(*LossyError)
+	return !ok
+}
+
+func lossy(path []string, format string, args ...interface{}) *LossyError {
+	return &LossyError{Path: strings.Join(path, "."), Reason: fmt.Sprintf(format, args...)}
+}
+
+// table is a TOML table or YAML mapping, with its entries in order.
+type table []entry
+
+// entry is an entry of a table. Its value is either a string, a bool, a
+// []interface{} holding values, a table, or nil.
+type entry struct {
+	key   string
+	value interface{}
+}
+
+// toTable converts a gcfg document to a table with the layout described in the
+// package documentation.
+func toTable(c *warnings.Collector, d *gcfg.Document) (table, error) {
+	var root table
+	sections := map[string]int{}
+	for _, s := range d.Sections {
+		name := strings.ToLower(s.Name)
+		i, ok := sections[name]
+		if !ok {
+			i = len(root)
+			sections[name] = i
+			root = append(root, entry{key: s.Name, value: table(nil)})
+		}
+		sectionTable := root[i].value.(table)
+
+		variables, err := variableTable(c, s)
+		if err != nil {
+			return nil, err
+		}
+		if s.Subsection == "" {
+			sectionTable = append(variables, sectionTable...)
+		} else {
+			sectionTable = append(sectionTable, entry{key: s.Subsection, value: variables})
+		}
+		root[i].value = sectionTable
+	}
+
+	// Variables and subsections share a table, so they can't have the same
+	// name.
+	for i, section := range root {
+		var sectionTable table
+		seen := map[string]bool{}
+		for _, e := range section.value.(table) {
+			if _, ok := e.value.(table); ok && seen[e.key] {
+				if err := c.Collect(lossy([]string{section.key, e.key}, "subsection has the same name as a variable")); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			seen[e.key] = true
+			sectionTable = append(sectionTable, e)
+		}
+		root[i].value = sectionTable
+	}
+	return root, nil
+}
+
+// variableTable returns the values of the variables of a section.
+func variableTable(c *warnings.Collector, s *gcfg.DocumentSection) (table, error) {
+	t := table{}
+	variables := map[string]int{}
+	reset := map[int]bool{}
+	for _, v := range s.Variables {
+		name := strings.ToLower(v.Name)
+		i, ok := variables[name]
+		if !ok {
+			i = len(t)
+			variables[name] = i
+			t = append(t, entry{key: v.Name})
+		}
+		if v.Blank {
+			switch t[i].value.(type) {
+			case nil, bool:
+				t[i].value = true
+			default:
+				// The values before the reset are dropped, and an empty
+				// array converts back to a blank variable.
+				t[i].value = []interface{}{}
+			}
+			reset[i] = true
+			continue
+		}
+		if reset[i] {
+			// Only the values after the reset are kept, and they would add
+			// to any values the variable already holds instead.
+			path := []string{s.Name, v.Name}
+			if s.Subsection != "" {
+				path = []string{s.Name, s.Subsection, v.Name}
+			}
+			if err := c.Collect(lossy(path, "values after a blank variable don't reset earlier values")); err != nil {
+				return nil, err
+			}
+			reset[i] = false
+		}
+		switch value := t[i].value.(type) {
+		case string:
+			t[i].value = []interface{}{value, v.Value}
+		case []interface{}:
+			t[i].value = append(value, v.Value)
+		default:
+			t[i].value = v.Value
+		}
+	}
+	return t, nil
+}
+
+// fromTable converts a table with the layout described in the package
+// documentation to a gcfg document.
+func fromTable(c *warnings.Collector, root table) (*gcfg.Document, error) {
+	d := &gcfg.Document{}
+	root, err := checkKeys(c, nil, root)
+	if err != nil {
+		return nil, err
+	}
+	for _, section := range root {
+		path := []string{section.key}
+		sectionTable, ok := section.value.(table)
+		if !ok {
+			if err := c.Collect(lossy(path, "top-level value is not a table")); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var subsections table
+		var variables table
+		for _, e := range sectionTable {
+			if _, ok := e.value.(table); ok {
+				subsections = append(subsections, e)
+			} else {
+				variables = append(variables, e)
+			}
+		}
+		if len(variables) > 0 || len(subsections) == 0 {
+			if err := addVariables(c, path, d.AddSection(section.key, ""), variables); err != nil {
+				return nil, err
+			}
+		}
+		for _, subsection := range subsections {
+			subsectionPath := append(path[:1:1], subsection.key)
+			if subsection.key == "" || strings.Contains(subsection.key, "\n") {
+				if err := c.Collect(lossy(subsectionPath, "invalid subsection name")); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if err := addVariables(c, subsectionPath, d.AddSection(section.key, subsection.key), subsection.value.(table)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return d, nil
+}
+
+func addVariables(c *warnings.Collector, path []string, s *gcfg.DocumentSection, variables table) error {
+	variables, err := checkKeys(c, path, variables)
+	if err != nil {
+		return err
+	}
+	for _, e := range variables {
+		variablePath := append(path[:len(path):len(path)], e.key)
+		switch value := e.value.(type) {
+		case string:
+			s.Variables = append(s.Variables, &gcfg.DocumentVariable{Name: e.key, Value: value})
+		case []interface{}:
+			if len(value) == 0 {
+				s.Variables = append(s.Variables, &gcfg.DocumentVariable{Name: e.key, Blank: true})
+			}
+			for _, v := range value {
+				if v, ok := v.(string); ok {
+					s.Variables = append(s.Variables, &gcfg.DocumentVariable{Name: e.key, Value: v})
+				} else if err := c.Collect(lossy(variablePath, "arrays may only hold strings, booleans and numbers")); err != nil {
+					return err
+				}
+			}
+		case table:
+			if err := c.Collect(lossy(variablePath, "tables may only be nested two levels deep")); err != nil {
+				return err
+			}
+		case nil:
+			if err := c.Collect(lossy(variablePath, "null value")); err != nil {
+				return err
+			}
+		default:
+			panic(fmt.Sprintf("unexpected value of type %T", value))
+		}
+	}
+	return nil
+}
+
+// checkKeys returns the entries of t whose keys can be used as gcfg section or
+// variable names, and reports the others.
+func checkKeys(c *warnings.Collector, path []string, t table) (table, error) {
+	var res table
+	seen := map[string]bool{}
+	for _, e := range t {
+		entryPath := append(path[:len(path):len(path)], e.key)
+		var err error
+		if !isIdentifier(e.key) {
+			err = lossy(entryPath, "not a valid gcfg name")
+		} else if seen[strings.ToLower(e.key)] {
+			err = lossy(entryPath, "name only differs in case from an earlier one")
+		}
+		if err != nil {
+			if err := c.Collect(err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		seen[strings.ToLower(e.key)] = true
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+// isIdentifier reports whether s is a valid gcfg section or variable name.
+func isIdentifier(s string) bool {
+	for i, r := range s {
+		if !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r) && r != '-') {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,220 @@
+package convert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/please-build/gcfg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `[section]
+name = value
+flag
+multi = a
+multi = "b \"c\""
+
+[section "sub.1"]
+path = /usr/bin
+
+[other "x"]
+reset = a
+reset
+
+[empty]
+`
+
+func readTestConfig(t *testing.T) *gcfg.Document {
+	d, err := gcfg.ReadDocument(strings.NewReader(testConfig))
+	require.NoError(t, err)
+	return d
+}
+
+func TestToTOML(t *testing.T) {
+	res, err := ToTOML(readTestConfig(t))
+	require.NoError(t, err)
+	assert.Equal(t, `[section]
+name = "value"
+flag = true
+multi = ["a", "b \"c\""]
+
+[section."sub.1"]
+path = "/usr/bin"
+
+[other.x]
+reset = []
+
+[empty]
+`, string(res))
+}
+
+func TestToYAML(t *testing.T) {
+	res, err := ToYAML(readTestConfig(t))
+	require.NoError(t, err)
+	assert.Equal(t, `section:
+    name: value
+    flag: true
+    multi:
+        - a
+        - b "c"
+    sub.1:
+        path: /usr/bin
+other:
+    x:
+        reset: []
+empty: {}
+`, string(res))
+}
+
+func TestRoundTrip(t *testing.T) {
+	d := readTestConfig(t)
+	d.Section("other", "x").Variables = d.Section("other", "x").Variables[:1]
+	d.Section("section", "").Variables[1] = &gcfg.DocumentVariable{Name: "flag", Value: "true"}
+
+	data, err := ToTOML(d)
+	require.NoError(t, err)
+	res, err := FromTOML(data)
+	require.NoError(t, err)
+	assert.Equal(t, d, res)
+
+	data, err = ToYAML(d)
+	require.NoError(t, err)
+	res, err = FromYAML(data)
+	require.NoError(t, err)
+	assert.Equal(t, d, res)
+}
+
+func TestRoundTripReset(t *testing.T) {
+	d, err := gcfg.ReadDocument(strings.NewReader("[section]\nmulti = a\nmulti = b\nmulti\n"))
+	require.NoError(t, err)
+	want := &gcfg.Document{Sections: []*gcfg.DocumentSection{
+		{Name: "section", Variables: []*gcfg.DocumentVariable{{Name: "multi", Blank: true}}},
+	}}
+
+	data, err := ToTOML(d)
+	require.NoError(t, err)
+	res, err := FromTOML(data)
+	require.NoError(t, err)
+	assert.Equal(t, want, res)
+
+	data, err = ToYAML(d)
+	require.NoError(t, err)
+	res, err = FromYAML(data)
+	require.NoError(t, err)
+	assert.Equal(t, want, res)
+}
+
+func TestFromTOML(t *testing.T) {
+	d, err := FromTOML([]byte(`
+[section]
+int = 0x10
+float = 1.5
+bool = false
+empty = []
+
+[section.sub]
+x = "y"
+`))
+	require.NoError(t, err)
+	assert.Equal(t, &gcfg.Document{Sections: []*gcfg.DocumentSection{
+		{Name: "section", Variables: []*gcfg.DocumentVariable{
+			{Name: "int", Value: "16"},
+			{Name: "float", Value: "1.5"},
+			{Name: "bool", Value: "false"},
+			{Name: "empty", Blank: true},
+		}},
+		{Name: "section", Subsection: "sub", Variables: []*gcfg.DocumentVariable{
+			{Name: "x", Value: "y"},
+		}},
+	}}, d)
+}
+
+func TestFromTOMLLossy(t *testing.T) {
+	d, err := FromTOML([]byte(`
+top = 1
+
+[section]
+snake_case = 1
+date = 1979-05-27
+nested = [[1], [2]]
+Name = "a"
+name = "b"
+
+[[tables]]
+x = 1
+`))
+	require.Error(t, err)
+	assert.NoError(t, gcfg.FatalOnly(err))
+	for _, msg := range []string{
+		"section.date: date or time converted to a string",
+		"section.snake_case: not a valid gcfg name",
+		"section.nested: arrays may only hold strings, booleans and numbers",
+		"section.name: name only differs in case from an earlier one",
+		"top: top-level value is not a table",
+		"tables: top-level value is not a table",
+	} {
+		assert.Contains(t, err.Error(), msg)
+	}
+	assert.Equal(t, &gcfg.Document{Sections: []*gcfg.DocumentSection{
+		{Name: "section", Variables: []*gcfg.DocumentVariable{
+			{Name: "date", Value: "1979-05-27"},
+			{Name: "Name", Value: "a"},
+		}},
+	}}, d)
+
+	_, err = FromTOML([]byte("[section"))
+	assert.Error(t, gcfg.FatalOnly(err))
+}
+
+func TestFromYAMLLossy(t *testing.T) {
+	d, err := FromYAML([]byte(`
+section:
+  none: null
+  list: &list [a, 1, true]
+  alias: *list
+  sub:
+    deeper:
+      x: 1
+`))
+	require.Error(t, err)
+	assert.NoError(t, gcfg.FatalOnly(err))
+	assert.Contains(t, err.Error(), "section.none: null value")
+	assert.Contains(t, err.Error(), "section.sub.deeper: tables may only be nested two levels deep")
+	assert.Equal(t, &gcfg.Document{Sections: []*gcfg.DocumentSection{
+		{Name: "section", Variables: []*gcfg.DocumentVariable{
+			{Name: "list", Value: "a"},
+			{Name: "list", Value: "1"},
+			{Name: "list", Value: "true"},
+			{Name: "alias", Value: "a"},
+			{Name: "alias", Value: "1"},
+			{Name: "alias", Value: "true"},
+		}},
+		{Name: "section", Subsection: "sub"},
+	}}, d)
+
+	_, err = FromYAML([]byte("- a\n"))
+	assert.Error(t, err)
+
+	d, err = FromYAML(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, &gcfg.Document{}, d)
+}
+
+func TestToTOMLLossy(t *testing.T) {
+	d, err := gcfg.ReadDocument(strings.NewReader("[section]\nx = 1\n[section \"x\"]\ny = 2\n"))
+	require.NoError(t, err)
+	res, err := ToTOML(d)
+	require.Error(t, err)
+	assert.NoError(t, gcfg.FatalOnly(err))
+	assert.Contains(t, err.Error(), "section.x: subsection has the same name as a variable")
+	assert.Equal(t, "[section]\nx = \"1\"\n", string(res))
+
+	d, err = gcfg.ReadDocument(strings.NewReader("[section \"sub\"]\nname = a\nname\nname = b\n"))
+	require.NoError(t, err)
+	res, err = ToTOML(d)
+	require.Error(t, err)
+	assert.NoError(t, gcfg.FatalOnly(err))
+	assert.Contains(t, err.Error(), "section.sub.name: values after a blank variable don't reset earlier values")
+	assert.Equal(t, "[section.sub]\nname = [\"b\"]\n", string(res))
+}
@@ -0,0 +1,231 @@
+package convert
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/please-build/gcfg"
+	"gopkg.in/warnings.v0"
+)
+
+// ToTOML converts a gcfg document to TOML.
+func ToTOML(d *gcfg.Document) ([]byte, error) {
+	c := warnings.NewCollector(isFatal)
+	root, err := toTable(c, d)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	for _, section := range root {
+		sectionTable := section.value.(table)
+		var subsections table
+		var variables table
+		for _, e := range sectionTable {
+			if _, ok := e.value.(table); ok {
+				subsections = append(subsections, e)
+			} else {
+				variables = append(variables, e)
+			}
+		}
+		if len(variables) > 0 || len(subsections) == 0 {
+			writeTOMLTable(&b, []string{section.key}, variables)
+		}
+		for _, subsection := range subsections {
+			writeTOMLTable(&b, []string{section.key, subsection.key}, subsection.value.(table))
+		}
+	}
+	return b.Bytes(), c.Done()
+}
+
+func writeTOMLTable(b *bytes.Buffer, path []string, variables table) {
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+	keys := make([]string, len(path))
+	for i, key := range path {
+		keys[i] = tomlKey(key)
+	}
+	b.WriteString("[" + strings.Join(keys, ".") + "]\n")
+	for _, e := range variables {
+		b.WriteString(tomlKey(e.key) + " = ")
+		switch value := e.value.(type) {
+		case string:
+			b.WriteString(tomlString(value))
+		case bool:
+			b.WriteString(strconv.FormatBool(value))
+		case []interface{}:
+			b.WriteString("[")
+			for i, v := range value {
+				if i > 0 {
+					b.WriteString(", ")
+				}
+				b.WriteString(tomlString(v.(string)))
+			}
+			b.WriteString("]")
+		}
+		b.WriteString("\n")
+	}
+}
+
+func tomlKey(key string) string {
+	for _, r := range key {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '_' || r == '-') {
+			return tomlString(key)
+		}
+	}
+	if key == "" {
+		return `""`
+	}
+	return key
+}
+
+// tomlString returns s as a TOML basic string.
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// FromTOML converts TOML data to a gcfg document.
+func FromTOML(data []byte) (*gcfg.Document, error) {
+	var m map[string]interface{}
+	md, err := toml.Decode(string(data), &m)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the keys in the order they appear in the data. Tables that are
+	// only defined implicitly, like "a" in "[a.b]", are ordered by their first
+	// key.
+	order := map[string]int{}
+	for i, key := range md.Keys() {
+		for j := 1; j <= len(key); j++ {
+			path := strings.Join(key[:j], "\x00")
+			if _, ok := order[path]; !ok {
+				order[path] = i
+			}
+		}
+	}
+
+	c := warnings.NewCollector(isFatal)
+	root, err := tomlTable(c, order, nil, m)
+	if err != nil {
+		return nil, err
+	}
+	d, err := fromTable(c, root)
+	if err != nil {
+		return nil, err
+	}
+	return d, c.Done()
+}
+
+func tomlTable(c *warnings.Collector, order map[string]int, path []string, m map[string]interface{}) (table, error) {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	position := func(key string) int {
+		if i, ok := order[strings.Join(append(path[:len(path):len(path)], key), "\x00")]; ok {
+			return i
+		}
+		return len(order)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if pi, pj := position(keys[i]), position(keys[j]); pi != pj {
+			return pi < pj
+		}
+		return keys[i] < keys[j]
+	})
+
+	t := make(table, 0, len(keys))
+	for _, key := range keys {
+		value, err := tomlValue(c, order, append(path[:len(path):len(path)], key), m[key])
+		if err != nil {
+			return nil, err
+		}
+		t = append(t, entry{key: key, value: value})
+	}
+	return t, nil
+}
+
+func tomlValue(c *warnings.Collector, order map[string]int, path []string, value interface{}) (interface{}, error) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		return tomlTable(c, order, path, value)
+	case []map[string]interface{}:
+		// Arrays of tables aren't keyed by position in order, so they're
+		// ordered by name. They can't be converted anyway.
+		values := make([]interface{}, len(value))
+		for i, v := range value {
+			t, err := tomlTable(c, nil, nil, v)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = t
+		}
+		return values, nil
+	case []interface{}:
+		values := make([]interface{}, len(value))
+		for i, v := range value {
+			var err error
+			if values[i], err = tomlValue(c, order, path, v); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	case string:
+		return value, nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case int64:
+		return strconv.FormatInt(value, 10), nil
+	case float64:
+		return strconv.FormatFloat(value, 'g', -1, 64), nil
+	case time.Time:
+		layout := time.RFC3339Nano
+		switch value.Location().String() {
+		case "datetime-local":
+			layout = "2006-01-02T15:04:05.999999999"
+		case "date-local":
+			layout = "2006-01-02"
+		case "time-local":
+			layout = "15:04:05.999999999"
+		}
+		if err := c.Collect(lossy(path, "date or time converted to a string")); err != nil {
+			return nil, err
+		}
+		return value.Format(layout), nil
+	}
+	panic(fmt.Sprintf("unexpected TOML value of type %T", value))
+}
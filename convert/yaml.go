@@ -0,0 +1,127 @@
+package convert
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/please-build/gcfg"
+	"gopkg.in/warnings.v0"
+	"gopkg.in/yaml.v3"
+)
+
+// ToYAML converts a gcfg document to YAML.
+func ToYAML(d *gcfg.Document) ([]byte, error) {
+	c := warnings.NewCollector(isFatal)
+	root, err := toTable(c, d)
+	if err != nil {
+		return nil, err
+	}
+	data, err := yaml.Marshal(yamlNode(root))
+	if err != nil {
+		return nil, err
+	}
+	return data, c.Done()
+}
+
+func yamlNode(value interface{}) *yaml.Node {
+	switch value := value.(type) {
+	case table:
+		n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, e := range value {
+			n.Content = append(n.Content, yamlNode(e.key), yamlNode(e.value))
+		}
+		return n
+	case []interface{}:
+		n := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, v := range value {
+			n.Content = append(n.Content, yamlNode(v))
+		}
+		return n
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(value)}
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	}
+	panic(fmt.Sprintf("unexpected value of type %T", value))
+}
+
+// FromYAML converts YAML data to a gcfg document.
+func FromYAML(data []byte) (*gcfg.Document, error) {
+	var n yaml.Node
+	if err := yaml.Unmarshal(data, &n); err != nil {
+		return nil, err
+	}
+	if len(n.Content) == 0 {
+		return &gcfg.Document{}, nil
+	}
+
+	c := warnings.NewCollector(isFatal)
+	root, err := yamlValue(c, nil, n.Content[0])
+	if err != nil {
+		return nil, err
+	}
+	rootTable, ok := root.(table)
+	if !ok {
+		return nil, fmt.Errorf("YAML document must be a mapping")
+	}
+	d, err := fromTable(c, rootTable)
+	if err != nil {
+		return nil, err
+	}
+	return d, c.Done()
+}
+
+func yamlValue(c *warnings.Collector, path []string, n *yaml.Node) (interface{}, error) {
+	switch n.Kind {
+	case yaml.AliasNode:
+		return yamlValue(c, path, n.Alias)
+	case yaml.MappingNode:
+		t := make(table, 0, len(n.Content)/2)
+		for i := 0; i < len(n.Content); i += 2 {
+			key := n.Content[i].Value
+			value, err := yamlValue(c, append(path[:len(path):len(path)], key), n.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			t = append(t, entry{key: key, value: value})
+		}
+		return t, nil
+	case yaml.SequenceNode:
+		values := make([]interface{}, len(n.Content))
+		for i, v := range n.Content {
+			var err error
+			if values[i], err = yamlValue(c, path, v); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+
+	switch n.ShortTag() {
+	case "!!null":
+		return nil, nil
+	case "!!bool":
+		var b bool
+		if err := n.Decode(&b); err != nil {
+			return nil, err
+		}
+		return strconv.FormatBool(b), nil
+	case "!!int":
+		var i interface{}
+		if err := n.Decode(&i); err != nil {
+			return nil, err
+		}
+		return fmt.Sprint(i), nil
+	case "!!float":
+		var f float64
+		if err := n.Decode(&f); err != nil {
+			return nil, err
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64), nil
+	case "!!timestamp":
+		if err := c.Collect(lossy(path, "date or time converted to a string")); err != nil {
+			return nil, err
+		}
+	}
+	return n.Value, nil
+}
@@ -0,0 +1,175 @@
+package gcfg
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/please-build/gcfg/scanner"
+)
+
+// ChangeKind is the kind of a Change.
+type ChangeKind int
+
+// Kinds of changes.
+const (
+	Added ChangeKind = iota + 1
+	Removed
+	Modified
+)
+
+// A Change is a difference in a variable between two configs.
+type Change struct {
+	Kind       ChangeKind
+	Section    string
+	Subsection string
+	Name       string
+	Old        []string // values in the old config; nil if the variable was added
+	New        []string // values in the new config; nil if the variable was removed
+}
+
+// Changes is a list of changes between two configs, as returned by Diff.
+type Changes []Change
+
+// Diff returns the differences between the configs a and b, which must be
+// pointers to structs of the same type.
+//
+// Variables are compared by their values as returned by Get, with the values
+// of multi-valued variables compared as ordered lists. Variables are only
+// added or removed when they are in subsections, or are extra values. Changes
+// are listed in the order Walk visits their variables.
+func Diff(a, b interface{}) (Changes, error) {
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return nil, fmt.Errorf("Configs must be of the same type, instead they are %T and %T", a, b)
+	}
+	positions := map[variableKey]variablePosition{}
+	oldValues, err := walkValues(a, positions)
+	if err != nil {
+		return nil, err
+	}
+	newValues, err := walkValues(b, positions)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []variableKey
+	for k := range oldValues {
+		keys = append(keys, k)
+	}
+	for k := range newValues {
+		if _, ok := oldValues[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return positions[keys[i]].less(positions[keys[j]])
+	})
+
+	var changes Changes
+	for _, k := range keys {
+		old, inOld := oldValues[k]
+		new, inNew := newValues[k]
+		switch {
+		case !inOld:
+			changes = append(changes, k.change(Added, nil, new))
+		case !inNew:
+			changes = append(changes, k.change(Removed, old, nil))
+		case !equalValues(old, new):
+			changes = append(changes, k.change(Modified, old, new))
+		}
+	}
+	return changes, nil
+}
+
+// String returns a human-readable rendering of the changes, similar to a
+// unified diff of gcfg files. Each changed section is introduced by a line
+// like "@@ [section] @@", followed by the removed values prefixed by "-" and
+// the added values prefixed by "+".
+func (c Changes) String() string {
+	var b strings.Builder
+	var section, subsection string
+	for i, change := range c {
+		if i == 0 || change.Section != section || change.Subsection != subsection {
+			section, subsection = change.Section, change.Subsection
+			if subsection == "" {
+				b.WriteString("@@ [" + section + "] @@\n")
+			} else {
+				b.WriteString("@@ [" + section + " \"" + subsectionEscape.Replace(subsection) + "\"] @@\n")
+			}
+		}
+		for _, value := range change.Old {
+			b.WriteString("-" + change.Name + " = " + scanner.Quote(value) + "\n")
+		}
+		if change.Kind == Removed && len(change.Old) == 0 {
+			b.WriteString("-" + change.Name + "\n")
+		}
+		for _, value := range change.New {
+			b.WriteString("+" + change.Name + " = " + scanner.Quote(value) + "\n")
+		}
+		if change.Kind == Added && len(change.New) == 0 {
+			b.WriteString("+" + change.Name + "\n")
+		}
+	}
+	return b.String()
+}
+
+type variableKey struct {
+	section, subsection, name string
+}
+
+func (k variableKey) change(kind ChangeKind, old, new []string) Change {
+	return Change{Kind: kind, Section: k.section, Subsection: k.subsection, Name: k.name, Old: old, New: new}
+}
+
+// variablePosition orders variables the way Walk visits them.
+type variablePosition struct {
+	section    int // index of the section's field in the config struct
+	subsection string
+	field      int // index of the variable's field in the section struct
+	name       string
+}
+
+func (p variablePosition) less(q variablePosition) bool {
+	switch {
+	case p.section != q.section:
+		return p.section < q.section
+	case p.subsection != q.subsection:
+		return p.subsection < q.subsection
+	case p.field != q.field:
+		return p.field < q.field
+	}
+	return p.name < q.name
+}
+
+// walkValues returns the values of the variables of config, and adds their
+// positions to positions.
+func walkValues(config interface{}, positions map[variableKey]variablePosition) (map[variableKey][]string, error) {
+	sections := map[string]int{}
+	if t := reflect.TypeOf(config); t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct {
+		for i := 0; i < t.Elem().NumField(); i++ {
+			sections[iniKey(t.Elem().Field(i))] = i
+		}
+	}
+
+	values := map[variableKey][]string{}
+	err := Walk(config, func(section, subsection, name string, field reflect.StructField, v []string) error {
+		k := variableKey{section, subsection, name}
+		values[k] = v
+		positions[k] = variablePosition{sections[section], subsection, field.Index[0], name}
+		return nil
+	})
+	return values, err
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package gcfg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type diffConfig struct {
+	Section struct {
+		Name  string
+		Multi []string
+		Level *int
+		Extra map[string]string `gcfg:"extra_values"`
+	}
+	Remote map[string]*struct {
+		URL string
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := &diffConfig{}
+	require.NoError(t, ReadStringInto(a, `
+[section]
+name = a
+multi = x
+multi = y
+gone = 1
+[remote "old"]
+url = o
+[remote "same"]
+url = s
+`))
+	b := &diffConfig{}
+	require.NoError(t, ReadStringInto(b, `
+[section]
+name = "b c"
+multi = y
+multi = x
+level = 1
+new = 2
+[remote "new"]
+url = n
+[remote "same"]
+url = s
+`))
+
+	changes, err := Diff(a, b)
+	require.NoError(t, err)
+	assert.Equal(t, Changes{
+		{Kind: Modified, Section: "section", Name: "name", Old: []string{"a"}, New: []string{"b c"}},
+		{Kind: Modified, Section: "section", Name: "multi", Old: []string{"x", "y"}, New: []string{"y", "x"}},
+		{Kind: Modified, Section: "section", Name: "level", Old: nil, New: []string{"1"}},
+		{Kind: Removed, Section: "section", Name: "gone", Old: []string{"1"}},
+		{Kind: Added, Section: "section", Name: "new", New: []string{"2"}},
+		{Kind: Added, Section: "remote", Subsection: "new", Name: "url", New: []string{"n"}},
+		{Kind: Removed, Section: "remote", Subsection: "old", Name: "url", Old: []string{"o"}},
+	}, changes)
+
+	assert.Equal(t, `@@ [section] @@
+-name = a
++name = b c
+-multi = x
+-multi = y
++multi = y
++multi = x
++level = 1
+-gone = 1
++new = 2
+@@ [remote "new"] @@
++url = n
+@@ [remote "old"] @@
+-url = o
+`, changes.String())
+
+	changes, err = Diff(a, a)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+	assert.Equal(t, "", changes.String())
+}
+
+func TestDiffErrors(t *testing.T) {
+	_, err := Diff(&diffConfig{}, &struct{ Section struct{ Name string } }{})
+	assert.Error(t, err)
+	_, err = Diff(diffConfig{}, diffConfig{})
+	assert.Error(t, err)
+}
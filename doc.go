@@ -140,6 +140,5 @@
 //  - writing gcfg files
 //  - error handling
 //    - make error context accessible programmatically?
-//    - limit input size?
 //
 package gcfg // import "github.com/please-build/gcfg"
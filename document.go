@@ -0,0 +1,119 @@
+package gcfg
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/please-build/gcfg/scanner"
+	"github.com/please-build/gcfg/token"
+	"gopkg.in/warnings.v0"
+)
+
+// Document holds gcfg data without interpreting it with a config struct.
+// Comments aren't kept.
+type Document struct {
+	Sections []*DocumentSection
+}
+
+// DocumentSection holds the variables of a section or subsection.
+type DocumentSection struct {
+	Name       string
+	Subsection string // empty if this isn't a subsection
+	Variables  []*DocumentVariable
+}
+
+// DocumentVariable is a single variable assignment. A variable that is
+// assigned several times, such as a multi-valued variable, has several
+// DocumentVariables in its section.
+type DocumentVariable struct {
+	Name  string
+	Value string
+	// Blank is true if the variable was given without a value, e.g. "name"
+	// rather than "name = value". A blank variable sets a boolean variable to
+	// true, and resets a multi-valued variable.
+	Blank bool
+}
+
+// ReadDocument reads gcfg formatted data from reader into a Document.
+//
+// Sections are listed in the order they first appear, and a section whose
+// header appears several times holds the variables from all of them. Like
+// ReadFileInto, ReadDocument skips a single leading UTF8 BOM sequence if it
+// exists.
+func ReadDocument(reader io.Reader) (*Document, error) {
+	src, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	src = skipLeadingUtf8Bom(src)
+
+	d := &Document{}
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	c := warnings.NewCollector(isFatal)
+	err = readIntoPass(context.Background(), c, func(sect, sub, name string, blank bool, value string) error {
+		s := d.AddSection(sect, sub)
+		if name != "" {
+			s.Variables = append(s.Variables, &DocumentVariable{Name: name, Value: value, Blank: blank})
+		}
+		return nil
+	}, fset, file, src, Limits{})
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Done(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Section returns the named section or subsection, or nil if there is no such
+// section. Section names are matched ignoring case, but subsection names
+// aren't.
+func (d *Document) Section(name, subsection string) *DocumentSection {
+	for _, s := range d.Sections {
+		if strings.EqualFold(s.Name, name) && s.Subsection == subsection {
+			return s
+		}
+	}
+	return nil
+}
+
+// AddSection returns the named section or subsection, adding an empty one to
+// the end of the document if there is no such section.
+func (d *Document) AddSection(name, subsection string) *DocumentSection {
+	if s := d.Section(name, subsection); s != nil {
+		return s
+	}
+	s := &DocumentSection{Name: name, Subsection: subsection}
+	d.Sections = append(d.Sections, s)
+	return s
+}
+
+var subsectionEscape = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// WriteTo writes the document to w in gcfg format.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	var b bytes.Buffer
+	for i, s := range d.Sections {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if s.Subsection == "" {
+			b.WriteString("[" + s.Name + "]\n")
+		} else {
+			b.WriteString("[" + s.Name + " \"" + subsectionEscape.Replace(s.Subsection) + "\"]\n")
+		}
+		for _, v := range s.Variables {
+			if v.Blank {
+				b.WriteString(v.Name + "\n")
+			} else {
+				b.WriteString(v.Name + " = " + scanner.Quote(v.Value) + "\n")
+			}
+		}
+	}
+	return b.WriteTo(w)
+}
@@ -0,0 +1,51 @@
+package gcfg
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadDocument(t *testing.T) {
+	d, err := ReadDocument(strings.NewReader("\ufeff; comment\n[Section]\nname = value\nmulti = a\nmulti = \"b c\"\n" +
+		"[section \"Sub\"]\nflag\n[section]\nother = x ; comment\n[empty]\n"))
+	require.NoError(t, err)
+	assert.Equal(t, &Document{Sections: []*DocumentSection{
+		{Name: "Section", Variables: []*DocumentVariable{
+			{Name: "name", Value: "value"},
+			{Name: "multi", Value: "a"},
+			{Name: "multi", Value: "b c"},
+			{Name: "other", Value: "x"},
+		}},
+		{Name: "section", Subsection: "Sub", Variables: []*DocumentVariable{
+			{Name: "flag", Blank: true},
+		}},
+		{Name: "empty"},
+	}}, d)
+	assert.Same(t, d.Sections[0], d.Section("SECTION", ""))
+	assert.Nil(t, d.Section("section", "sub"))
+
+	_, err = ReadDocument(strings.NewReader("name = value\n"))
+	assert.Error(t, err)
+}
+
+func TestDocumentWriteTo(t *testing.T) {
+	d := &Document{}
+	s := d.AddSection("section", "")
+	s.Variables = append(s.Variables, &DocumentVariable{Name: "name", Value: " value; with \"quotes\""})
+	s = d.AddSection("section", `a "sub"`)
+	s.Variables = append(s.Variables, &DocumentVariable{Name: "flag", Blank: true}, &DocumentVariable{Name: "empty"})
+	assert.Same(t, s, d.AddSection("Section", `a "sub"`))
+
+	var b bytes.Buffer
+	_, err := d.WriteTo(&b)
+	require.NoError(t, err)
+	assert.Equal(t, "[section]\nname = \" value; with \\\"quotes\\\"\"\n\n[section \"a \\\"sub\\\"\"]\nflag\nempty = \n", b.String())
+
+	read, err := ReadDocument(&b)
+	require.NoError(t, err)
+	assert.Equal(t, d, read)
+}
@@ -5,7 +5,8 @@ import (
 	"reflect"
 )
 
-// Get retrieves the values of a config field.
+// Get retrieves the values of a config field. Single-valued fields holding a
+// nil pointer have no values.
 func Get(config interface{}, section, subsection, name string) ([]string, error) {
 	field, err := parseField(section, subsection, name)
 	if err != nil {
@@ -65,24 +66,11 @@ func Get(config interface{}, section, subsection, name string) ([]string, error)
 		}
 	}
 
-	// If the field is a slice.
-	if isMultiVal(variableValue) {
-		m := make([]string, 0, variableValue.Len())
-		for i := 0; i < variableValue.Len(); i++ {
-			res, err := iniValue(variableValue.Index(i))
-			if err != nil {
-				return nil, fmt.Errorf("Failed to retrieve field %s: %s", field, err)
-			}
-			m = append(m, res)
-		}
-		return m, nil
-	}
-
-	res, err := iniValue(variableValue)
+	values, err := iniValues(variableValue)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to retrieve field %s: %s", field, err)
 	}
-	return []string{res}, nil
+	return values, nil
 }
 
 // Tries to obtain `key` through the `gcfg:"extra_values"` functionality.
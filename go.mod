@@ -1,8 +1,15 @@
 module github.com/please-build/gcfg
 
-go 1.16
+go 1.19
 
 require (
-	github.com/stretchr/testify v1.7.0 // indirect
+	github.com/BurntSushi/toml v1.2.1
+	github.com/stretchr/testify v1.7.0
 	gopkg.in/warnings.v0 v0.1.2
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 )
@@ -5,102 +5,302 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+
+	"gopkg.in/warnings.v0"
 )
 
 // RawJSON returns the config raw encoded JSON value.
 // This value implements both Marshaler and Unmarshaler interfaces.
+//
+// Values are encoded with json.Marshal, so they may differ from their gcfg
+// representation; see JSON for an encoding consistent with Stringify. Sections
+// and variables are encoded in the order Walk visits them.
 func RawJSON(config interface{}) ([]byte, error) {
+	root := newJSONObject()
+	var object *jsonObject
+	var prefix string
+	err := walk(config, true, func(section, subsection string, kind sectionKind) error {
+		object, prefix = root.object(section), ""
+		switch {
+		case kind == structMapSection:
+			object = object.object(subsection)
+		case kind == stringMapSection && subsection != "":
+			// See decodeStringMap.
+			prefix = subsection + " "
+		}
+		return nil
+	}, func(section, subsection, name string, field reflect.StructField, value reflect.Value) error {
+		res, err := json.Marshal(value.Interface())
+		if err != nil {
+			return err
+		}
+		object.set(prefix+name, json.RawMessage(res))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	res, err := json.Marshal(root)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(res), nil
+}
+
+// JSONMode controls how JSON encodes variable values.
+type JSONMode int
+
+const (
+	// JSONStrings encodes every value as a JSON string holding its gcfg
+	// representation.
+	JSONStrings JSONMode = iota
+	// JSONTyped encodes boolean and integer values as JSON booleans and
+	// numbers, and every other value as a JSON string.
+	JSONTyped
+)
+
+// JSON returns the JSON encoding of config, which must be a pointer to a
+// struct. It has the same shape as the encoding returned by RawJSON, but
+// values are converted the same way as by Stringify, and multi-valued
+// variables are always encoded as arrays. Single-valued variables holding a
+// nil pointer are encoded as null.
+//
+// Unlike RawJSON, JSON returns an error if config has a top-level field that
+// can't hold a section.
+func JSON(config interface{}, mode JSONMode) ([]byte, error) {
+	if mode != JSONStrings && mode != JSONTyped {
+		return nil, fmt.Errorf("Unknown JSON mode: %d", mode)
+	}
 	configPtr := reflect.ValueOf(config)
 	if configPtr.Kind() != reflect.Ptr || configPtr.Elem().Kind() != reflect.Struct {
 		return nil, fmt.Errorf("Config must be a pointer to a struct")
 	}
-	configValue := configPtr.Elem()
+	schema, err := SchemaOf(configPtr.Type())
+	if err != nil {
+		return nil, err
+	}
 
-	res := []byte("{")
-	for i := 0; i < configValue.NumField(); i++ {
-		fieldValue := configValue.Field(i)
-		fieldStruct := configValue.Type().Field(i)
-		if !fieldValue.CanInterface() {
-			continue
+	// Create every section and subsection up front, so that they are
+	// encoded in order even if they don't hold any variables.
+	root := newJSONObject()
+	for _, section := range schema.Sections {
+		sectionObject := root.object(section.Name)
+		if section.Subsections && section.ExtraValues == nil {
+			sectionValue := configPtr.Elem().FieldByIndex(section.Field.Index)
+			for _, subsection := range sortedKeys(sectionValue) {
+				if !sectionValue.MapIndex(reflect.ValueOf(subsection)).IsNil() {
+					sectionObject.object(subsection)
+				}
+			}
 		}
+	}
 
-		iniFieldName := iniKey(fieldStruct)
+	err = Walk(config, func(section, subsection, name string, field reflect.StructField, values []string) error {
+		sectionSchema := schema.Section(section)
+		variable := sectionSchema.Variable(name)
+		if variable == nil || variable.Field.Name != field.Name {
+			variable = sectionSchema.ExtraValues
+		}
 
-		if fieldValue.Kind() == reflect.Struct {
-			innerRes, err := marshalStruct(fieldValue)
-			if err != nil {
-				return nil, err
-			}
-			res = append(res, fmt.Sprintf("\"%s\":%s,", iniFieldName, innerRes)...)
-		} else if fieldValue.Kind() == reflect.Map {
-			if fieldStruct.Type.Key().Kind() != reflect.String {
-				return nil, fmt.Errorf("The map keys must to be of string type, instead they are of %s type", fieldStruct.Type.Key().Kind())
+		object := root.object(section)
+		if sectionSchema.Subsections {
+			if sectionSchema.Field.Type.Elem().Kind() == reflect.String {
+				// See decodeStringMap.
+				if subsection != "" {
+					name = subsection + " " + name
+				}
+			} else {
+				object = object.object(subsection)
 			}
+		}
 
-			if fieldStruct.Type.Elem().Kind() == reflect.String {
-				innerRes, err := json.Marshal(fieldValue.Interface())
-				if err != nil {
-					return nil, err
-				}
-				res = append(res, fmt.Sprintf("\"%s\":%s,", iniFieldName, innerRes)...)
-			} else if fieldStruct.Type.Elem().Kind() == reflect.Ptr && fieldStruct.Type.Elem().Elem().Kind() == reflect.Struct {
-				res = append(res, `"`+iniFieldName+`":{`...)
-				iter := fieldValue.MapRange()
-				for iter.Next() {
-					innerRes, err := marshalStruct(iter.Value().Elem())
-					if err != nil {
-						return nil, err
-					}
-					res = append(res, fmt.Sprintf("\"%s\":%s,", iter.Key(), innerRes)...)
-				}
-				res = append(bytes.TrimSuffix(res, []byte(",")), `},`...)
+		jsonType := "string"
+		if mode == JSONTyped {
+			jsonType = variable.jsonType()
+		}
+		if !variable.Multi {
+			if len(values) == 0 {
+				object.set(name, nil)
 			} else {
-				return nil, fmt.Errorf("The map values must either be of string or *struct type, instead they are of %s type", fieldStruct.Type.Elem().Kind())
+				object.set(name, jsonScalar(jsonType, values[0]))
 			}
+			return nil
+		}
+		array := make([]interface{}, len(values))
+		for i, value := range values {
+			array[i] = jsonScalar(jsonType, value)
 		}
+		object.set(name, array)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	res = append(bytes.TrimSuffix(res, []byte(",")), '}')
+	return json.Marshal(root)
+}
 
-	return json.RawMessage(res), nil
+// jsonType returns the JSON type used for the variable's values by JSONTyped.
+func (v *VariableSchema) jsonType() string {
+	switch {
+	case v.IntMode != 0:
+		return "integer"
+	case v.Type.Kind() == reflect.Bool && !reflect.PtrTo(v.Type).Implements(textUnmarshalerType):
+		return "boolean"
+	}
+	return "string"
+}
+
+// jsonObject is a JSON object that keeps its keys in the order they were
+// first set.
+type jsonObject struct {
+	keys   []string
+	values map[string]interface{}
 }
 
-func marshalStruct(fieldValue reflect.Value) ([]byte, error) {
-	if fieldValue.Kind() != reflect.Struct {
-		return nil, fmt.Errorf("Expected a struct type from %v, but instead got %s\n", fieldValue, fieldValue.Kind())
+func newJSONObject() *jsonObject {
+	return &jsonObject{values: map[string]interface{}{}}
+}
+
+func (o *jsonObject) set(key string, value interface{}) {
+	if _, exists := o.values[key]; !exists {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// object returns the object stored under key, creating it if necessary.
+func (o *jsonObject) object(key string) *jsonObject {
+	if child, ok := o.values[key].(*jsonObject); ok {
+		return child
 	}
+	child := newJSONObject()
+	o.set(key, child)
+	return child
+}
 
-	res := []byte("{")
-	for i := 0; i < fieldValue.NumField(); i++ {
-		subfieldValue := fieldValue.Field(i)
-		subfieldStruct := fieldValue.Type().Field(i)
-		if !subfieldValue.CanInterface() {
-			continue
+func (o *jsonObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
 		}
+		v, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// FromJSON reads JSON data in the format produced by RawJSON and sets the
+// values into the corresponding fields in config.
+//
+// JSON strings, numbers and booleans are converted to strings and parsed the
+// same way as values read from a gcfg file, and arrays are treated as a
+// repeated variable. As when reading a gcfg file, values of multi-valued
+// variables are appended to any values they already hold, and data that
+// doesn't belong to any part of the config structure is reported as a
+// warning (see FatalOnly). Variables with null values are ignored.
+func FromJSON(data []byte, config interface{}) error {
+	configPtr := reflect.ValueOf(config)
+	if configPtr.Kind() != reflect.Ptr || configPtr.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Config must be a pointer to a struct")
+	}
+	configValue := configPtr.Elem()
+
+	var sections map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&sections); err != nil {
+		return err
+	}
 
-		if subfieldStruct.Tag.Get("gcfg") == "extra_values" {
-			if subfieldStruct.Type != reflect.TypeOf(map[string]string{}) && subfieldStruct.Type != reflect.TypeOf(map[string][]string{}) {
-				return nil, fmt.Errorf("Expected either a map[string]string or map[string][]string type, but instead got %s\n", subfieldStruct.Type)
+	// As when reading, struct sections are set first, so that "default-"
+	// sections are filled in before map sections' subsections are created.
+	c := warnings.NewCollector(isFatal)
+	for _, subsectPass := range []bool{false, true} {
+		for _, section := range sortedKeys(reflect.ValueOf(sections)) {
+			variables, ok := sections[section].(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("Section %s must be a JSON object", section)
 			}
 
-			iter := subfieldValue.MapRange()
-			for iter.Next() {
-				innerRes, err := json.Marshal(iter.Value().Interface())
-				if err != nil {
-					return nil, err
+			sectionValue, _ := fieldFold(configValue, section)
+			if !sectionValue.IsValid() {
+				if !subsectPass {
+					if err := c.Collect(extraData{loc: loc{section: section}}); err != nil {
+						return err
+					}
 				}
-				res = append(res, fmt.Sprintf("\"%s\":%s,", iter.Key(), innerRes)...)
+				continue
 			}
-			res = bytes.TrimSuffix(res, []byte(","))
-		} else {
-			innerRes, err := json.Marshal(subfieldValue.Interface())
-			if err != nil {
-				return nil, err
+			if !isSectionField(sectionValue) {
+				return fmt.Errorf("Invalid section field: %s", section)
+			}
+			if (sectionValue.Kind() == reflect.Map) != subsectPass {
+				continue
 			}
-			res = append(res, fmt.Sprintf("\"%s\":%s", iniKey(subfieldStruct), innerRes)...)
-		}
 
-		res = append(res, ',')
+			if subsectPass && sectionValue.Type().Elem().Kind() == reflect.Ptr {
+				for _, subsection := range sortedKeys(reflect.ValueOf(variables)) {
+					subsectionVariables, ok := variables[subsection].(map[string]interface{})
+					if !ok {
+						return fmt.Errorf("Subsection %s.%s must be a JSON object", section, subsection)
+					}
+					if err := setJSONSection(c, config, section, subsection, subsectionVariables, subsectPass); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if err := setJSONSection(c, config, section, "", variables, subsectPass); err != nil {
+				return err
+			}
+		}
 	}
+	return c.Done()
+}
 
-	return append(bytes.TrimSuffix(res, []byte(",")), '}'), nil
+func setJSONSection(c *warnings.Collector, config interface{}, section, subsection string,
+	variables map[string]interface{}, subsectPass bool) error {
+	//
+	// Ensure a container object is created, even if there are no variables.
+	if err := c.Collect(set(c, config, section, subsection, "", true, "", subsectPass, false)); err != nil {
+		return err
+	}
+	for _, name := range sortedKeys(reflect.ValueOf(variables)) {
+		values, ok := variables[name].([]interface{})
+		if !ok {
+			values = []interface{}{variables[name]}
+		}
+		for _, value := range values {
+			var v string
+			switch value := value.(type) {
+			case nil:
+				continue
+			case string:
+				v = value
+			case json.Number:
+				v = value.String()
+			case bool:
+				v = fmt.Sprint(value)
+			default:
+				return locErr{msg: "value must be a JSON string, number, boolean or array of them",
+					loc: loc{section: section, subsection: &subsection, variable: &name}}
+			}
+			if err := set(c, config, section, subsection, name, false, v, subsectPass, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
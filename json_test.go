@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"math/big"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRawJson1(t *testing.T) {
@@ -169,3 +171,179 @@ func TestRawJson5(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, expectedResult, out.String())
 }
+
+type fromJSONSection struct {
+	Bar     string
+	FBar    bool `gcfg:"f--bar"`
+	FooBaz  int
+	Bar_Foo *big.Int
+	Baz_Baz []string
+	Ptr     *uint
+	Extra   map[string][]string `gcfg:"extra_values"`
+}
+
+type fromJSONConfig struct {
+	Foo  fromJSONSection
+	Sub  map[string]*fromJSONSection
+	Tags map[string]string
+}
+
+func TestFromJSONRoundTrip(t *testing.T) {
+	in := &fromJSONConfig{
+		Foo: fromJSONSection{
+			Bar:     "value1",
+			FBar:    true,
+			FooBaz:  -5,
+			Bar_Foo: big.NewInt(10),
+			Baz_Baz: []string{"value3", "value4"},
+			Extra:   map[string][]string{"bar-key": {"bar-value", "bar-value2"}},
+		},
+		Sub: map[string]*fromJSONSection{
+			"one": {Bar: "value5", Baz_Baz: []string{"value6"}},
+			"two": {},
+		},
+		Tags: map[string]string{"a": "b", "my sub c": "d"},
+	}
+	data, err := RawJSON(in)
+	require.NoError(t, err)
+
+	out := &fromJSONConfig{}
+	require.NoError(t, FromJSON(data, out))
+	assert.Equal(t, in, out)
+}
+
+func TestFromJSONAppends(t *testing.T) {
+	config := &struct {
+		Section struct {
+			Name  string
+			Multi []int
+			Ptr   *bool
+		}
+	}{}
+	config.Section.Multi = []int{1}
+	require.NoError(t, FromJSON([]byte(`{"section": {"name": "x", "multi": [2, 3], "ptr": null}}`), config))
+	assert.Equal(t, "x", config.Section.Name)
+	assert.Equal(t, []int{1, 2, 3}, config.Section.Multi)
+	assert.Nil(t, config.Section.Ptr)
+}
+
+func TestFromJSONDefaults(t *testing.T) {
+	type section struct {
+		Bar string
+		Num int
+	}
+	type config struct {
+		Alpha        map[string]*section
+		DefaultAlpha section `gcfg:"default-alpha"`
+	}
+	data := `{"alpha": {"x": {"bar": "b"}}, "default-alpha": {"num": 7}}`
+
+	fromJSON := &config{}
+	require.NoError(t, FromJSON([]byte(data), fromJSON))
+	assert.Equal(t, &section{Bar: "b", Num: 7}, fromJSON.Alpha["x"])
+
+	read := &config{}
+	require.NoError(t, ReadStringInto(read, "[alpha \"x\"]\nbar = b\n[default-alpha]\nnum = 7\n"))
+	assert.Equal(t, read, fromJSON)
+}
+
+func TestFromJSONErrors(t *testing.T) {
+	config := &struct {
+		Section struct {
+			Level int
+		}
+	}{}
+	assert.Error(t, FromJSON([]byte(`{}`), *config))
+	assert.Error(t, FromJSON([]byte(`[]`), config))
+	assert.Error(t, FromJSON([]byte(`{"section": 1}`), config))
+	assert.Error(t, FromJSON([]byte(`{"section": {"level": "one"}}`), config))
+	assert.Error(t, FromJSON([]byte(`{"section": {"level": {"value": 1}}}`), config))
+
+	unsupported := &struct {
+		Num    int
+		IntKey map[int]*struct{ Level int }
+		IntMap map[string]int
+	}{}
+	assert.EqualError(t, FromJSON([]byte(`{"num": {}}`), unsupported), "Invalid section field: num")
+	assert.EqualError(t, FromJSON([]byte(`{"intkey": {"sub": {"level": 1}}}`), unsupported), "Invalid section field: intkey")
+	assert.EqualError(t, FromJSON([]byte(`{"intmap": {"key": 1}}`), unsupported), "Invalid section field: intmap")
+
+	err := FromJSON([]byte(`{"section": {"level": 1, "unknown": 2}, "other": {}}`), config)
+	assert.Error(t, err)
+	assert.NoError(t, FatalOnly(err))
+	assert.Equal(t, 1, config.Section.Level)
+}
+
+type jsonConfig struct {
+	Section struct {
+		Name    string
+		Enabled bool
+		Mode    int `gcfg:",int=do"`
+		Size    *big.Int
+		Value   subtypeStructWithMarshaler
+		Level   *int
+		Ports   []uint16
+		Extra   map[string][]string `gcfg:"extra_values"`
+	}
+	Remote map[string]*struct {
+		URL string
+	}
+	Alias map[string]string
+}
+
+func newJSONConfig() *jsonConfig {
+	config := &jsonConfig{}
+	config.Section.Name = "name"
+	config.Section.Enabled = true
+	config.Section.Mode = 0755
+	config.Section.Size = big.NewInt(10)
+	config.Section.Value = subtypeStructWithMarshaler{Value: "value"}
+	config.Section.Extra = map[string][]string{"other": {"a", "b"}}
+	config.Remote = map[string]*struct{ URL string }{"origin": {URL: "url"}, "empty": nil}
+	config.Alias = map[string]string{"a": "b", "sub c": "d"}
+	return config
+}
+
+func TestJSONStrings(t *testing.T) {
+	res, err := JSON(newJSONConfig(), JSONStrings)
+	require.NoError(t, err)
+	assert.Equal(t, `{"section":{"name":"name","enabled":"true","mode":"493","size":"10","value":"value","level":null,"ports":[],"other":["a","b"]},`+
+		`"remote":{"origin":{"url":"url"}},"alias":{"a":"b","sub c":"d"}}`, string(res))
+}
+
+func TestJSONTyped(t *testing.T) {
+	res, err := JSON(newJSONConfig(), JSONTyped)
+	require.NoError(t, err)
+	assert.Equal(t, `{"section":{"name":"name","enabled":true,"mode":493,"size":10,"value":"value","level":null,"ports":[],"other":["a","b"]},`+
+		`"remote":{"origin":{"url":"url"}},"alias":{"a":"b","sub c":"d"}}`, string(res))
+}
+
+func TestJSONFromJSON(t *testing.T) {
+	in := newJSONConfig()
+	in.Section.Value = subtypeStructWithMarshaler{}
+	in.Section.Ports = []uint16{80, 443}
+	delete(in.Remote, "empty")
+	for _, mode := range []JSONMode{JSONStrings, JSONTyped} {
+		res, err := JSON(in, mode)
+		require.NoError(t, err)
+
+		// subtypeStructWithMarshaler can't be read back, so drop it.
+		out := &jsonConfig{}
+		require.NoError(t, FromJSON([]byte(strings.Replace(string(res), `"value":"",`, "", 1)), out))
+		assert.Equal(t, in, out)
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	_, err := JSON(newJSONConfig(), JSONMode(-1))
+	assert.Error(t, err)
+
+	_, err = JSON(*newJSONConfig(), JSONStrings)
+	assert.Error(t, err)
+
+	_, err = JSON(&struct {
+		Section struct{ Name string }
+		Name    string
+	}{}, JSONStrings)
+	assert.Error(t, err)
+}
@@ -0,0 +1,185 @@
+package gcfg
+
+import (
+	"encoding"
+	"encoding/json"
+	"math/big"
+	"reflect"
+
+	"github.com/please-build/gcfg/types"
+)
+
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	bigIntType        = reflect.TypeOf(big.Int{})
+)
+
+// JSONSchema returns a JSON Schema (draft 2020-12) describing the JSON that
+// RawJSON produces for configs of the schema's type.
+//
+// Sections are objects holding their variables as properties, and sections
+// with subsections are objects holding the subsections as additional
+// properties. Extra values are additional properties of their section.
+// Multi-valued variables are arrays. Values declared by `enum`, `min` and
+// `max` tags are included, as are the descriptions declared by `help` tags and
+// the defaults recorded by DefaultsFrom.
+func (s *Schema) JSONSchema() ([]byte, error) {
+	properties := make(map[string]interface{}, len(s.Sections))
+	for _, section := range s.Sections {
+		properties[section.Name] = section.jsonSchema()
+	}
+	root := map[string]interface{}{
+		"$schema":              jsonSchemaDialect,
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if s.Type.Name() != "" {
+		root["title"] = s.Type.Name()
+	}
+	return json.MarshalIndent(root, "", "  ")
+}
+
+func (s *SectionSchema) jsonSchema() map[string]interface{} {
+	object := map[string]interface{}{"type": "object"}
+	if s.Help != "" {
+		object["description"] = s.Help
+	}
+	if len(s.Variables) > 0 {
+		properties := make(map[string]interface{}, len(s.Variables))
+		for _, v := range s.Variables {
+			properties[v.Name] = v.jsonSchema()
+		}
+		object["properties"] = properties
+	}
+	if s.ExtraValues != nil {
+		object["additionalProperties"] = s.ExtraValues.jsonSchema()
+	} else {
+		object["additionalProperties"] = false
+	}
+
+	// RawJSON doesn't nest the variables of map[string]string sections by
+	// subsection; see decodeStringMap.
+	if !s.Subsections || s.Field.Type.Elem().Kind() == reflect.String {
+		return object
+	}
+	subsections := map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": object,
+	}
+	if s.Help != "" {
+		subsections["description"] = s.Help
+	}
+	return subsections
+}
+
+func (v *VariableSchema) jsonSchema() map[string]interface{} {
+	t := v.Field.Type
+	if t.Kind() == reflect.Map {
+		// Extra values and map sections.
+		t = t.Elem()
+	}
+	schema := v.jsonValueSchema(t)
+	if v.Help != "" {
+		schema["description"] = v.Help
+	}
+
+	if v.Default != nil {
+		jsonType, _ := v.jsonValueSchema(v.Type)["type"].(string)
+		if v.Multi {
+			dflt := make([]interface{}, len(v.Default))
+			for i, value := range v.Default {
+				dflt[i] = jsonScalar(jsonType, value)
+			}
+			schema["default"] = dflt
+		} else if len(v.Default) == 1 {
+			schema["default"] = jsonScalar(jsonType, v.Default[0])
+		}
+	}
+	return schema
+}
+
+// jsonValueSchema returns the schema of the JSON json.Marshal produces for
+// values of type t.
+func (v *VariableSchema) jsonValueSchema(t reflect.Type) map[string]interface{} {
+	switch {
+	case t == bigIntType || t == reflect.PtrTo(bigIntType):
+		return v.jsonScalarSchema("integer")
+	case t.Implements(jsonMarshalerType):
+		return map[string]interface{}{}
+	case t.Implements(textMarshalerType):
+		return v.jsonScalarSchema("string")
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := v.jsonValueSchema(t.Elem())
+		if jsonType, ok := schema["type"].(string); ok {
+			schema["type"] = []string{jsonType, "null"}
+		}
+		return schema
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// Byte slices are encoded as base64 strings.
+			return map[string]interface{}{"type": "string"}
+		}
+		return map[string]interface{}{"type": "array", "items": v.jsonValueSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": v.jsonValueSchema(t.Elem())}
+	case reflect.Struct:
+		return map[string]interface{}{"type": "object"}
+	case reflect.Bool:
+		return v.jsonScalarSchema("boolean")
+	case reflect.Float32, reflect.Float64:
+		return v.jsonScalarSchema("number")
+	case reflect.String:
+		return v.jsonScalarSchema("string")
+	}
+	if isIntKind(t.Kind()) {
+		return v.jsonScalarSchema("integer")
+	}
+	return map[string]interface{}{}
+}
+
+func (v *VariableSchema) jsonScalarSchema(jsonType string) map[string]interface{} {
+	schema := map[string]interface{}{"type": jsonType}
+	if len(v.Enum) > 0 {
+		enum := make([]interface{}, len(v.Enum))
+		for i, value := range v.Enum {
+			enum[i] = jsonScalar(jsonType, value)
+		}
+		schema["enum"] = enum
+	}
+	if jsonType == "integer" || jsonType == "number" {
+		if v.Min != "" {
+			schema["minimum"] = json.Number(v.Min)
+		}
+		if v.Max != "" {
+			schema["maximum"] = json.Number(v.Max)
+		}
+	}
+	return schema
+}
+
+// jsonScalar converts an ini value to a JSON value of type jsonType, falling
+// back to a string if it can't be converted.
+func jsonScalar(jsonType, value string) interface{} {
+	switch jsonType {
+	case "boolean":
+		if b, err := types.ParseBool(value); err == nil {
+			return b
+		}
+	case "integer", "number":
+		if isJSONNumber(value) {
+			return json.Number(value)
+		}
+	}
+	return value
+}
+
+func isJSONNumber(value string) bool {
+	return value != "" && (value[0] == '-' || '0' <= value[0] && value[0] <= '9') && json.Valid([]byte(value))
+}
@@ -0,0 +1,80 @@
+package gcfg
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type jsonSchemaConfig struct {
+	Section struct {
+		Name    string `enum:"foo,bar" help:"The name"`
+		Level   int    `min:"1" max:"10"`
+		Enabled *bool
+		Multi   []uint16 `enum:"80,443"`
+		Value   subtypeStructWithMarshaler
+	}
+	Remote map[string]*struct {
+		URL   string
+		Extra map[string][]string `gcfg:"extra_values"`
+	}
+	Alias map[string]string
+}
+
+func TestJSONSchema(t *testing.T) {
+	s, err := SchemaOf(reflect.TypeOf(jsonSchemaConfig{}))
+	require.NoError(t, err)
+
+	config := &jsonSchemaConfig{}
+	config.Section.Name = "foo"
+	config.Section.Level = 5
+	config.Section.Multi = []uint16{80}
+	require.NoError(t, s.DefaultsFrom(config))
+
+	res, err := s.JSONSchema()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "jsonSchemaConfig",
+  "type": "object",
+  "additionalProperties": false,
+  "properties": {
+    "section": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "name": {"type": "string", "description": "The name", "enum": ["foo", "bar"], "default": "foo"},
+        "level": {"type": "integer", "minimum": 1, "maximum": 10, "default": 5},
+        "enabled": {"type": ["boolean", "null"]},
+        "multi": {"type": "array", "items": {"type": "integer", "enum": [80, 443]}, "default": [80]},
+        "value": {"type": "string", "default": ""}
+      }
+    },
+    "remote": {
+      "type": "object",
+      "additionalProperties": {
+        "type": "object",
+        "additionalProperties": {"type": "array", "items": {"type": "string"}},
+        "properties": {
+          "url": {"type": "string"}
+        }
+      }
+    },
+    "alias": {
+      "type": "object",
+      "additionalProperties": {"type": "string"}
+    }
+  }
+}`, string(res))
+}
+
+func TestJSONSchemaInvalidBound(t *testing.T) {
+	_, err := SchemaOf(reflect.TypeOf(struct {
+		Section struct {
+			Level int `min:"one"`
+		}
+	}{}))
+	assert.Error(t, err)
+}
@@ -0,0 +1,212 @@
+package gcfg
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// MergeStrategy is a way of merging a variable of one config into another.
+type MergeStrategy int
+
+// Merge strategies.
+const (
+	// MergeReplace replaces the destination's value with the source's,
+	// unless the source's value is the zero value.
+	MergeReplace MergeStrategy = iota
+	// MergeAppend appends the source's values of a multi-valued variable to
+	// the destination's. Other variables are replaced as with MergeReplace.
+	MergeAppend
+	// MergeKeep keeps the destination's value, unless it is the zero value.
+	MergeKeep
+)
+
+var mergeStrategies = map[string]MergeStrategy{
+	"replace": MergeReplace,
+	"append":  MergeAppend,
+	"keep":    MergeKeep,
+}
+
+// MergeOptions holds the options for Merge.
+type MergeOptions struct {
+	// Strategy is used for variables that don't have a merge tag option, and
+	// for the values of map sections and extra values.
+	Strategy MergeStrategy
+}
+
+// Merge merges the config src into dst, which must be pointers to structs of
+// the same type.
+//
+// Each variable is merged according to the strategy given by its struct tag
+// option ",merge=strategy", where strategy is one of replace, append or keep,
+// or otherwise by opts.Strategy. Since the zero value can't be told apart from
+// a value that wasn't set, zero values in src never replace values in dst; use
+// a pointer type for variables where this matters.
+//
+// Subsections of map sections are unioned: subsections only in src are copied
+// to dst, and those in both are merged. The values of map[string]string
+// sections and of `gcfg:"extra_values"` fields are merged key by key. dst
+// doesn't share any slices, maps or subsections with src afterwards, but
+// pointers to single values may be shared.
+func Merge(dst, src interface{}, opts MergeOptions) error {
+	if reflect.TypeOf(dst) != reflect.TypeOf(src) {
+		return fmt.Errorf("Configs must be of the same type, instead they are %T and %T", dst, src)
+	}
+	dstPtr, srcPtr := reflect.ValueOf(dst), reflect.ValueOf(src)
+	if dstPtr.Kind() != reflect.Ptr || dstPtr.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Config must be a pointer to a struct")
+	}
+	if dstPtr.IsNil() || srcPtr.IsNil() {
+		return fmt.Errorf("Configs must not be nil")
+	}
+	dstValue, srcValue := dstPtr.Elem(), srcPtr.Elem()
+
+	// Check every merge tag up front, so that dst is left unchanged if one
+	// is invalid.
+	schema, err := SchemaOf(dstPtr.Type())
+	if err != nil {
+		return err
+	}
+	for _, section := range schema.Sections {
+		for _, variable := range section.Variables {
+			if _, err := mergeStrategy(variable.Field, opts.Strategy); err != nil {
+				return fmt.Errorf("Failed to merge section %s: %s", section.Name, err)
+			}
+		}
+	}
+
+	for _, section := range schema.Sections {
+		dstField := dstValue.FieldByIndex(section.Field.Index)
+		srcField := srcValue.FieldByIndex(section.Field.Index)
+		kind, _ := sectionKindOf(section.Name, section.Field.Type) // checked by SchemaOf
+		switch kind {
+		case structSection:
+			if err := mergeSection(dstField, srcField, opts); err != nil {
+				return fmt.Errorf("Failed to merge section %s: %s", section.Name, err)
+			}
+		case stringMapSection:
+			mergeMap(dstField, srcField, opts.Strategy)
+		case structMapSection:
+			if err := mergeSubsections(dstField, srcField, opts); err != nil {
+				return fmt.Errorf("Failed to merge section %s: %s", section.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// mergeSubsections merges the subsections of the map section src into dst.
+func mergeSubsections(dst, src reflect.Value, opts MergeOptions) error {
+	if src.Len() == 0 {
+		return nil
+	}
+	if dst.IsNil() {
+		dst.Set(reflect.MakeMap(dst.Type()))
+	}
+	for _, subsection := range sortedKeys(src) {
+		k := reflect.ValueOf(subsection).Convert(dst.Type().Key())
+		srcSub := src.MapIndex(k)
+		if srcSub.IsNil() {
+			continue
+		}
+		dstSub := dst.MapIndex(k)
+		if !dstSub.IsValid() || dstSub.IsNil() {
+			// Merging into a new subsection copies src's.
+			dstSub = reflect.New(dst.Type().Elem().Elem())
+			dst.SetMapIndex(k, dstSub)
+		}
+		if err := mergeSection(dstSub.Elem(), srcSub.Elem(), opts); err != nil {
+			return fmt.Errorf("subsection %q: %s", subsection, err)
+		}
+	}
+	return nil
+}
+
+// mergeSection merges the variables of the section src into dst.
+func mergeSection(dst, src reflect.Value, opts MergeOptions) error {
+	for i := 0; i < dst.NumField(); i++ {
+		dstField, srcField := dst.Field(i), src.Field(i)
+		fieldStruct := dst.Type().Field(i)
+		if !dstField.CanSet() {
+			continue
+		}
+
+		if fieldStruct.Tag.Get("gcfg") == "extra_values" {
+			mergeMap(dstField, srcField, opts.Strategy)
+			continue
+		}
+
+		strategy, err := mergeStrategy(fieldStruct, opts.Strategy)
+		if err != nil {
+			return err
+		}
+		mergeVariable(dstField, srcField, strategy)
+	}
+	return nil
+}
+
+// mergeStrategy returns the strategy given by the merge tag option of a
+// variable's field, or dflt if it has none.
+func mergeStrategy(fieldStruct reflect.StructField, dflt MergeStrategy) (MergeStrategy, error) {
+	s := newTag(fieldStruct.Tag.Get("gcfg")).merge
+	if s == "" {
+		return dflt, nil
+	}
+	strategy, ok := mergeStrategies[s]
+	if !ok {
+		return 0, fmt.Errorf("Invalid merge strategy %q for field %s", s, fieldStruct.Name)
+	}
+	return strategy, nil
+}
+
+// mergeVariable merges the variable src into dst.
+func mergeVariable(dst, src reflect.Value, strategy MergeStrategy) {
+	if src.IsZero() {
+		return
+	}
+	if strategy == MergeKeep && !dst.IsZero() {
+		return
+	}
+	if !isMultiVal(dst) {
+		dst.Set(src)
+		return
+	}
+
+	if dst.Kind() == reflect.Ptr {
+		if dst.IsNil() || strategy != MergeAppend {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		dst, src = dst.Elem(), src.Elem()
+	}
+	if strategy != MergeAppend {
+		dst.Set(reflect.Zero(dst.Type()))
+	}
+	dst.Set(reflect.AppendSlice(dst, src))
+}
+
+// mergeMap merges the map src, which is a map section or extra values, into
+// dst key by key.
+func mergeMap(dst, src reflect.Value, strategy MergeStrategy) {
+	if src.Len() == 0 {
+		return
+	}
+	if dst.IsNil() {
+		dst.Set(reflect.MakeMap(dst.Type()))
+	}
+	for _, key := range sortedKeys(src) {
+		k := reflect.ValueOf(key)
+		srcValue, dstValue := src.MapIndex(k), dst.MapIndex(k)
+		if dstValue.IsValid() && strategy == MergeKeep {
+			continue
+		}
+		if srcValue.Kind() != reflect.Slice {
+			dst.SetMapIndex(k, srcValue)
+			continue
+		}
+		// The values of map[string][]string extra values.
+		values := reflect.Zero(srcValue.Type())
+		if dstValue.IsValid() && strategy == MergeAppend {
+			values = dstValue
+		}
+		dst.SetMapIndex(k, reflect.AppendSlice(values, srcValue))
+	}
+}
@@ -0,0 +1,149 @@
+package gcfg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mergeConfig struct {
+	Section struct {
+		Name    string
+		Enabled *bool
+		Replace []string
+		Append  []string            `gcfg:",merge=append"`
+		Keep    string              `gcfg:",merge=keep"`
+		Extra   map[string][]string `gcfg:"extra_values"`
+	}
+	Remote map[string]*struct {
+		URL   string
+		Paths []string `gcfg:",merge=append"`
+	}
+	Env map[string]string
+}
+
+func TestMerge(t *testing.T) {
+	dst := &mergeConfig{}
+	require.NoError(t, ReadStringInto(dst, `
+[section]
+name = dst
+enabled = true
+replace = a
+append = a
+keep = dst
+x = 1
+[remote "both"]
+url = dst
+paths = a
+[remote "dst"]
+url = d
+[env "dst"]
+home = d
+`))
+	src := &mergeConfig{}
+	require.NoError(t, ReadStringInto(src, `
+[section]
+enabled = false
+replace = b
+replace = c
+append = b
+keep = src
+x = 2
+y = 3
+[remote "both"]
+paths = b
+[remote "src"]
+url = s
+paths = c
+[env "dst"]
+home = s
+[env "src"]
+home = s
+`))
+
+	require.NoError(t, Merge(dst, src, MergeOptions{}))
+	assert.Equal(t, "dst", dst.Section.Name, "zero values don't replace")
+	assert.False(t, *dst.Section.Enabled)
+	assert.Equal(t, []string{"b", "c"}, dst.Section.Replace)
+	assert.Equal(t, []string{"a", "b"}, dst.Section.Append)
+	assert.Equal(t, "dst", dst.Section.Keep)
+	assert.Equal(t, map[string][]string{"x": {"2"}, "y": {"3"}}, dst.Section.Extra)
+	assert.Len(t, dst.Remote, 3)
+	assert.Equal(t, "dst", dst.Remote["both"].URL)
+	assert.Equal(t, []string{"a", "b"}, dst.Remote["both"].Paths)
+	assert.Equal(t, "d", dst.Remote["dst"].URL)
+	assert.Equal(t, "s", dst.Remote["src"].URL)
+	assert.Equal(t, map[string]string{"dst home": "s", "src home": "s"}, dst.Env)
+
+	// dst doesn't share anything with src.
+	src.Remote["src"].Paths[0] = "changed"
+	src.Section.Replace[0] = "changed"
+	assert.Equal(t, []string{"c"}, dst.Remote["src"].Paths)
+	assert.Equal(t, []string{"b", "c"}, dst.Section.Replace)
+	assert.NotSame(t, src.Remote["src"], dst.Remote["src"])
+}
+
+func TestMergeStrategy(t *testing.T) {
+	dst := &mergeConfig{}
+	require.NoError(t, ReadStringInto(dst, `
+[section]
+name = dst
+replace = a
+x = 1
+[env]
+home = d
+`))
+	src := &mergeConfig{}
+	require.NoError(t, ReadStringInto(src, `
+[section]
+name = src
+replace = b
+keep = src
+x = 2
+y = 3
+[env]
+home = s
+user = s
+`))
+
+	keep := *dst
+	require.NoError(t, Merge(&keep, src, MergeOptions{Strategy: MergeKeep}))
+	assert.Equal(t, "dst", keep.Section.Name)
+	assert.Equal(t, []string{"a"}, keep.Section.Replace)
+	assert.Equal(t, "src", keep.Section.Keep, "zero values are filled in")
+	assert.Equal(t, map[string][]string{"x": {"1"}, "y": {"3"}}, keep.Section.Extra)
+	assert.Equal(t, map[string]string{"home": "d", "user": "s"}, keep.Env)
+
+	dst = &mergeConfig{}
+	require.NoError(t, ReadStringInto(dst, "[section]\nname = dst\nreplace = a\nx = 1\n"))
+	require.NoError(t, Merge(dst, src, MergeOptions{Strategy: MergeAppend}))
+	assert.Equal(t, "src", dst.Section.Name)
+	assert.Equal(t, []string{"a", "b"}, dst.Section.Replace)
+	assert.Equal(t, map[string][]string{"x": {"1", "2"}, "y": {"3"}}, dst.Section.Extra)
+}
+
+func TestMergeErrors(t *testing.T) {
+	type badStrategy struct {
+		Section struct {
+			Name string `gcfg:",merge=first"`
+		}
+	}
+	src := &badStrategy{}
+	src.Section.Name = "x"
+	assert.EqualError(t, Merge(&badStrategy{}, src, MergeOptions{}), `Failed to merge section section: Invalid merge strategy "first" for field Name`)
+
+	type laterBadStrategy struct {
+		A struct{ X string }
+		B struct {
+			Name string `gcfg:",merge=first"`
+		}
+	}
+	dst, src2 := &laterBadStrategy{}, &laterBadStrategy{}
+	dst.A.X, src2.A.X = "dst", "src"
+	assert.EqualError(t, Merge(dst, src2, MergeOptions{}), `Failed to merge section b: Invalid merge strategy "first" for field Name`)
+	assert.Equal(t, "dst", dst.A.X)
+
+	assert.EqualError(t, Merge(&badStrategy{}, &mergeConfig{}, MergeOptions{}), "Configs must be of the same type, instead they are *gcfg.badStrategy and *gcfg.mergeConfig")
+	assert.EqualError(t, Merge(badStrategy{}, badStrategy{}, MergeOptions{}), "Config must be a pointer to a struct")
+}
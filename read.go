@@ -2,8 +2,10 @@ package gcfg
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"os"
 	"strings"
@@ -13,50 +15,23 @@ import (
 	"gopkg.in/warnings.v0"
 )
 
-var unescape = map[rune]rune{'\\': '\\', '"': '"', 'n': '\n', 't': '\t'}
 var utf8Bom = []byte("\ufeff")
 
-// no error: invalid literals should be caught by scanner
-func unquote(s string) string {
-	u, q, esc := make([]rune, 0, len(s)), false, false
-	for _, c := range s {
-		if esc {
-			uc, ok := unescape[c]
-			switch {
-			case ok:
-				u = append(u, uc)
-				fallthrough
-			case !q && c == '\n':
-				esc = false
-				continue
-			}
-			panic("invalid escape sequence")
-		}
-		switch c {
-		case '"':
-			q = !q
-		case '\\':
-			esc = true
-		default:
-			u = append(u, c)
-		}
-	}
-	if q {
-		panic("missing end quote")
-	}
-	if esc {
-		panic("invalid escape sequence")
-	}
-	return string(u)
-}
+// setFunc stores a variable read from gcfg data. An empty name means that
+// only a section/subsection header has been read.
+type setFunc func(sect, sub, name string, blank bool, value string) error
 
-func readIntoPass(c *warnings.Collector, config interface{}, fset *token.FileSet,
-	file *token.File, src []byte, subsectPass bool) error {
+func readIntoPass(ctx context.Context, c *warnings.Collector, set setFunc, fset *token.FileSet,
+	file *token.File, src []byte, limits Limits) error {
 	//
 	var s scanner.Scanner
 	var errs scanner.ErrorList
 	s.Init(file, src, func(p token.Position, m string) { errs.Add(p, m) }, 0)
 	sect, sectsub := "", ""
+	// Section names and variable names are case insensitive, so they're
+	// counted by their lower case form.
+	subsections := map[string]map[string]bool{}
+	values := map[[3]string]int{}
 	pos, tok, lit := s.Scan()
 	errfn := func(msg string) error {
 		return fmt.Errorf("%s: %s", fset.Position(pos), msg)
@@ -73,6 +48,10 @@ func readIntoPass(c *warnings.Collector, config interface{}, fset *token.FileSet
 		case token.EOL, token.COMMENT:
 			pos, tok, lit = s.Scan()
 		case token.LBRACK:
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("%s: %w", fset.Position(pos), err)
+			}
+			hpos := pos
 			pos, tok, lit = s.Scan()
 			if errs.Len() > 0 {
 				if err := c.Collect(errs.Err()); err != nil {
@@ -92,8 +71,12 @@ func readIntoPass(c *warnings.Collector, config interface{}, fset *token.FileSet
 				}
 			}
 			if tok == token.STRING {
-				sectsub = unquote(lit)
-				if sectsub == "" {
+				var err error
+				if sectsub, err = scanner.Unquote(lit); err != nil {
+					if err := c.Collect(errfn(err.Error())); err != nil {
+						return err
+					}
+				} else if sectsub == "" {
 					if err := c.Collect(errfn("empty subsection name")); err != nil {
 						return err
 					}
@@ -121,10 +104,23 @@ func readIntoPass(c *warnings.Collector, config interface{}, fset *token.FileSet
 					return err
 				}
 			}
+			if limits.MaxSubsections > 0 && sectsub != "" {
+				subs := subsections[strings.ToLower(sect)]
+				if subs == nil {
+					subs = map[string]bool{}
+					subsections[strings.ToLower(sect)] = subs
+				}
+				subs[sectsub] = true
+				if len(subs) > limits.MaxSubsections {
+					if err := c.Collect(fmt.Errorf("%s: section %q has more than %d subsections", fset.Position(hpos), sect, limits.MaxSubsections)); err != nil {
+						return err
+					}
+				}
+			}
 			// If a section/subsection header was found, ensure a
 			// container object is created, even if there are no
 			// variables further down.
-			err := c.Collect(set(c, config, sect, sectsub, "", true, "", subsectPass))
+			err := c.Collect(set(sect, sectsub, "", true, ""))
 			if err != nil {
 				return err
 			}
@@ -134,7 +130,7 @@ func readIntoPass(c *warnings.Collector, config interface{}, fset *token.FileSet
 					return err
 				}
 			}
-			n := lit
+			n, npos := lit, pos
 			pos, tok, lit = s.Scan()
 			if errs.Len() > 0 {
 				return errs.Err()
@@ -157,7 +153,12 @@ func readIntoPass(c *warnings.Collector, config interface{}, fset *token.FileSet
 						return err
 					}
 				}
-				v = unquote(lit)
+				var err error
+				if v, err = scanner.Unquote(lit); err != nil {
+					if err := c.Collect(errfn(err.Error())); err != nil {
+						return err
+					}
+				}
 				pos, tok, lit = s.Scan()
 				if errs.Len() > 0 {
 					if err := c.Collect(errs.Err()); err != nil {
@@ -170,7 +171,18 @@ func readIntoPass(c *warnings.Collector, config interface{}, fset *token.FileSet
 					}
 				}
 			}
-			err := set(c, config, sect, sectsub, n, blank, v, subsectPass)
+			if limits.MaxValues > 0 {
+				// A blank value resets a multi-valued variable.
+				k := [3]string{strings.ToLower(sect), sectsub, strings.ToLower(n)}
+				if blank {
+					values[k] = 0
+				} else if values[k]++; values[k] > limits.MaxValues {
+					if err := c.Collect(fmt.Errorf("%s: variable %q has more than %d values", fset.Position(npos), n, limits.MaxValues)); err != nil {
+						return err
+					}
+				}
+			}
+			err := set(sect, sectsub, n, blank, v)
 			if err != nil {
 				return err
 			}
@@ -187,17 +199,36 @@ func readIntoPass(c *warnings.Collector, config interface{}, fset *token.FileSet
 	}
 }
 
-func readInto(config interface{}, fset *token.FileSet, file *token.File,
-	src []byte) error {
+func readInto(ctx context.Context, config interface{}, fset *token.FileSet, file *token.File,
+	src []byte, limits Limits) error {
 	//
-	c := warnings.NewCollector(isFatal)
-	err := readIntoPass(c, config, fset, file, src, false)
-	if err != nil {
-		return err
+	if limits.MaxLineLength > 0 {
+		for offs := 0; offs < len(src); {
+			n := bytes.IndexByte(src[offs:], '\n')
+			if n < 0 {
+				n = len(src) - offs
+			}
+			length := n
+			if length > 0 && src[offs+length-1] == '\r' {
+				length--
+			}
+			if length > limits.MaxLineLength {
+				file.SetLinesForContent(src)
+				return fmt.Errorf("%s: line is longer than %d bytes", fset.Position(file.Pos(offs+limits.MaxLineLength)), limits.MaxLineLength)
+			}
+			offs += n + 1
+		}
 	}
-	err = readIntoPass(c, config, fset, file, src, true)
-	if err != nil {
-		return err
+
+	c := warnings.NewCollector(isFatal)
+	for _, subsectPass := range []bool{false, true} {
+		subsectPass := subsectPass
+		err := readIntoPass(ctx, c, func(sect, sub, name string, blank bool, value string) error {
+			return set(c, config, sect, sub, name, blank, value, subsectPass, false)
+		}, fset, file, src, limits)
+		if err != nil {
+			return err
+		}
 	}
 	return c.Done()
 }
@@ -205,13 +236,71 @@ func readInto(config interface{}, fset *token.FileSet, file *token.File,
 // ReadInto reads gcfg formatted data from reader and sets the values into the
 // corresponding fields in config.
 func ReadInto(config interface{}, reader io.Reader) error {
-	src, err := ioutil.ReadAll(reader)
+	return NewDecoder(reader).Decode(config)
+}
+
+// Limits bounds the size and complexity of the gcfg data read by a Decoder,
+// for reading data from untrusted sources. Zero values mean no limit.
+type Limits struct {
+	// MaxBytes is the maximum size of the data.
+	MaxBytes int64
+	// MaxLineLength is the maximum length of a line in bytes, not including
+	// the line ending.
+	MaxLineLength int
+	// MaxSubsections is the maximum number of distinct subsections of a
+	// section.
+	MaxSubsections int
+	// MaxValues is the maximum number of values of a variable in a section or
+	// subsection; that is, the number of times it may be set since it was
+	// last reset by a blank value.
+	MaxValues int
+}
+
+// A Decoder reads gcfg formatted data from an input stream.
+type Decoder struct {
+	r      io.Reader
+	Limits Limits
+}
+
+// NewDecoder returns a new decoder that reads from r, with no limits.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Decode reads gcfg formatted data from the decoder's input and sets the
+// values into the corresponding fields in config, like ReadInto. Data
+// exceeding the decoder's limits causes a fatal error giving the position it
+// was found at.
+func (d *Decoder) Decode(config interface{}) error {
+	return d.DecodeContext(context.Background(), config)
+}
+
+// DecodeContext is like Decode, but stops reading when ctx is done. ctx is
+// checked before and after reading the input, and before each section; if it
+// is done, DecodeContext returns ctx.Err() wrapped with the current position.
+func (d *Decoder) DecodeContext(ctx context.Context, config interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r := d.r
+	if d.Limits.MaxBytes > 0 {
+		r = io.LimitReader(r, d.Limits.MaxBytes+1)
+	}
+	src, err := ioutil.ReadAll(r)
 	if err != nil {
 		return err
 	}
 	fset := token.NewFileSet()
 	file := fset.AddFile("", fset.Base(), len(src))
-	return readInto(config, fset, file, src)
+	if d.Limits.MaxBytes > 0 && int64(len(src)) > d.Limits.MaxBytes {
+		file.SetLinesForContent(src)
+		return fmt.Errorf("%s: data is larger than %d bytes", fset.Position(file.Pos(len(src)-1)), d.Limits.MaxBytes)
+	}
+	if err := ctx.Err(); err != nil {
+		file.SetLinesForContent(src)
+		return fmt.Errorf("%s: %w", fset.Position(file.Pos(len(src))), err)
+	}
+	return readInto(ctx, config, fset, file, src, d.Limits)
 }
 
 // ReadStringInto reads gcfg formatted data from str and sets the values into
@@ -236,13 +325,30 @@ func ReadFileInto(config interface{}, filename string) error {
 	if err != nil {
 		return err
 	}
+	return readFileInto(config, filename, src)
+}
+
+// ReadFSInto reads gcfg formatted data from the file name in fsys and sets the
+// values into the corresponding fields in config. name must be a path as
+// accepted by fsys.Open, such as a path to a file embedded in an embed.FS.
+//
+// Like ReadFileInto, ReadFSInto skips a single leading UTF8 BOM sequence if it
+// exists.
+func ReadFSInto(config interface{}, fsys fs.FS, name string) error {
+	src, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+	return readFileInto(config, name, src)
+}
 
+func readFileInto(config interface{}, filename string, src []byte) error {
 	// Skips a single leading UTF8 BOM sequence if it exists.
 	src = skipLeadingUtf8Bom(src)
 
 	fset := token.NewFileSet()
 	file := fset.AddFile(filename, fset.Base(), len(src))
-	return readInto(config, fset, file, src)
+	return readInto(context.Background(), config, fset, file, src, Limits{})
 }
 
 func skipLeadingUtf8Bom(src []byte) []byte {
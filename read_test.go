@@ -2,12 +2,17 @@ package gcfg
 
 import (
 	"bytes"
+	"context"
 	"encoding"
+	"errors"
 	"fmt"
+	"io/fs"
 	"math/big"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 const (
@@ -375,6 +380,39 @@ func TestReadFileIntoNotepad(t *testing.T) {
 	}
 }
 
+func TestReadFSInto(t *testing.T) {
+	res := &struct{ X甲 struct{ X乙 string } }{}
+	err := ReadFSInto(res, os.DirFS("testdata"), "notepad.ini")
+	if err != nil {
+		t.Error(err)
+	}
+	if "丁" != res.X甲.X乙 {
+		t.Errorf("got %q, wanted %q", res.X甲.X乙, "丁")
+	}
+}
+
+func TestReadFSIntoMapFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config/default.gcfg": {Data: []byte("\ufeff[section]\nname=value\n")},
+		"config/invalid.gcfg": {Data: []byte("[section]\n= value\n")},
+	}
+	res := &struct{ Section struct{ Name string } }{}
+	if err := ReadFSInto(res, fsys, "config/default.gcfg"); err != nil {
+		t.Error(err)
+	}
+	if "value" != res.Section.Name {
+		t.Errorf("got %q, wanted %q", res.Section.Name, "value")
+	}
+
+	err := ReadFSInto(res, fsys, "config/invalid.gcfg")
+	if err == nil || !strings.HasPrefix(err.Error(), "config/invalid.gcfg:2:1: ") {
+		t.Errorf("got %v, wanted an error positioned in config/invalid.gcfg", err)
+	}
+	if err := ReadFSInto(res, fsys, "config/missing.gcfg"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v, wanted %v", err, fs.ErrNotExist)
+	}
+}
+
 func TestReadStringIntoSubsectDefaults(t *testing.T) {
 	type subsect struct {
 		Color       string
@@ -514,3 +552,68 @@ func TestFatalOnly(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestDecoderLimits(t *testing.T) {
+	type config struct {
+		Section struct {
+			Name  string
+			Multi []string
+		}
+		Sub map[string]*struct{ Name string }
+	}
+	for _, tt := range []struct {
+		limits Limits
+		data   string
+		err    string
+	}{
+		{Limits{MaxBytes: 19}, "[section]\nname = a\n", ""},
+		{Limits{MaxBytes: 18}, "[section]\nname = a\n", "2:9: data is larger than 18 bytes"},
+		{Limits{MaxLineLength: 9}, "[section]\nname = a\r\n", ""},
+		{Limits{MaxLineLength: 8}, "[section]\nname = a\r\n", "1:9: line is longer than 8 bytes"},
+		{Limits{MaxLineLength: 9}, "[section]\nname = abc", "2:10: line is longer than 9 bytes"},
+		{Limits{MaxLineLength: 9}, "[section]\r\nname = ab\r\n", ""},
+		{Limits{MaxLineLength: 9}, "[section]\r\nname = abc\r\n", "2:10: line is longer than 9 bytes"},
+		{Limits{MaxSubsections: 2}, "[sub \"a\"]\n[sub \"b\"]\n[SUB \"a\"]\n", ""},
+		{Limits{MaxSubsections: 2}, "[sub \"a\"]\n[sub \"b\"]\n[sub \"c\"]\n", "3:1: section \"sub\" has more than 2 subsections"},
+		{Limits{MaxValues: 2}, "[section]\nmulti = a\nmulti = b\nmulti\nMulti = c\nmulti = d\n", ""},
+		{Limits{MaxValues: 2}, "[section]\nmulti = a\nmulti = b\nMULTI = c\n", "4:1: variable \"MULTI\" has more than 2 values"},
+	} {
+		d := NewDecoder(strings.NewReader(tt.data))
+		d.Limits = tt.limits
+		err := d.Decode(&config{})
+		if tt.err == "" && err != nil {
+			t.Errorf("%+v %q: unexpected error %v", tt.limits, tt.data, err)
+		} else if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err) || FatalOnly(err) == nil) {
+			t.Errorf("%+v %q: got error %v, wanted fatal %q", tt.limits, tt.data, err, tt.err)
+		}
+	}
+}
+
+// cancelValue cancels a context when it's read.
+type cancelValue struct{ cancel context.CancelFunc }
+
+func (v *cancelValue) UnmarshalText(text []byte) error {
+	v.cancel()
+	return nil
+}
+
+func TestDecodeContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	res := &struct {
+		A struct{ Value cancelValue }
+		B struct{ Name string }
+	}{}
+	res.A.Value.cancel = cancel
+	err := NewDecoder(strings.NewReader("[a]\nvalue = x\n[b]\nname = y\n")).DecodeContext(ctx, res)
+	if !errors.Is(err, context.Canceled) || !strings.HasPrefix(err.Error(), "3:1: ") {
+		t.Errorf("got %v, wanted %v at 3:1", err, context.Canceled)
+	}
+	if res.B.Name != "" {
+		t.Errorf("got %q, wanted section b not to be read", res.B.Name)
+	}
+
+	err = NewDecoder(strings.NewReader("[b]\nname = y\n")).DecodeContext(ctx, res)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, wanted %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,206 @@
+package gcfg
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/please-build/gcfg/scanner"
+	"github.com/please-build/gcfg/types"
+)
+
+// ReferenceFormat is an output format for Schema.WriteReference.
+type ReferenceFormat int
+
+// Output formats for Schema.WriteReference.
+const (
+	Markdown ReferenceFormat = iota
+	HTML
+)
+
+// WriteReference writes reference documentation for the sections and
+// variables of the schema to w. Each variable is listed with its type,
+// default, allowed values and the description given by its `help` tag.
+func (s *Schema) WriteReference(w io.Writer, format ReferenceFormat) error {
+	var b bytes.Buffer
+	switch format {
+	case Markdown:
+		s.writeMarkdown(&b)
+	case HTML:
+		s.writeHTML(&b)
+	default:
+		return fmt.Errorf("Unknown reference format: %d", format)
+	}
+	_, err := b.WriteTo(w)
+	return err
+}
+
+// WriteSample writes a sample gcfg file to w, with every variable commented
+// out and set to its default, annotated with the description given by its
+// `help` tag.
+func (s *Schema) WriteSample(w io.Writer) error {
+	var b bytes.Buffer
+	for i, section := range s.Sections {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		writeSampleComment(&b, section.Help)
+		b.WriteString(section.heading() + "\n")
+		for j, v := range section.Variables {
+			if j > 0 {
+				b.WriteString("\n")
+			}
+			writeSampleComment(&b, v.Help)
+			if allowed := v.allowedValues(); allowed != "" {
+				b.WriteString("; Allowed values: " + allowed + "\n")
+			}
+			if len(v.Default) == 0 {
+				b.WriteString("; " + v.Name + " =\n")
+			}
+			for _, value := range v.Default {
+				b.WriteString(strings.TrimRight("; "+v.Name+" = "+scanner.Quote(value), " ") + "\n")
+			}
+		}
+		if section.ExtraValues != nil {
+			if len(section.Variables) > 0 {
+				b.WriteString("\n")
+			}
+			b.WriteString("; Any other variable may be set in this section.\n")
+		}
+	}
+	_, err := b.WriteTo(w)
+	return err
+}
+
+func (s *Schema) writeMarkdown(b *bytes.Buffer) {
+	b.WriteString("# Configuration reference\n")
+	for _, section := range s.Sections {
+		fmt.Fprintf(b, "\n## `%s`\n", section.heading())
+		if section.Help != "" {
+			b.WriteString("\n" + section.Help + "\n")
+		}
+		for _, v := range section.Variables {
+			fmt.Fprintf(b, "\n### %s\n", v.Name)
+			if v.Help != "" {
+				b.WriteString("\n" + v.Help + "\n")
+			}
+			fmt.Fprintf(b, "\n- Type: `%s`\n", v.typeName())
+			if v.Multi {
+				b.WriteString("- Multi-valued: repeat the variable to give several values\n")
+			}
+			if len(v.Default) > 0 {
+				fmt.Fprintf(b, "- Default: `%s`\n", strings.Join(v.Default, "`, `"))
+			}
+			if allowed := v.allowedValues(); allowed != "" {
+				fmt.Fprintf(b, "- Allowed values: %s\n", allowed)
+			}
+		}
+		if section.ExtraValues != nil {
+			b.WriteString("\nAny other variable may be set in this section.\n")
+		}
+	}
+}
+
+func (s *Schema) writeHTML(b *bytes.Buffer) {
+	b.WriteString("<h1>Configuration reference</h1>\n")
+	for _, section := range s.Sections {
+		fmt.Fprintf(b, "<h2 id=\"%s\"><code>%s</code></h2>\n", html.EscapeString(section.Name), html.EscapeString(section.heading()))
+		if section.Help != "" {
+			fmt.Fprintf(b, "<p>%s</p>\n", html.EscapeString(section.Help))
+		}
+		if len(section.Variables) > 0 {
+			b.WriteString("<dl>\n")
+		}
+		for _, v := range section.Variables {
+			fmt.Fprintf(b, "<dt id=\"%s.%s\"><code>%s</code></dt>\n<dd>\n",
+				html.EscapeString(section.Name), html.EscapeString(v.Name), html.EscapeString(v.Name))
+			if v.Help != "" {
+				fmt.Fprintf(b, "<p>%s</p>\n", html.EscapeString(v.Help))
+			}
+			b.WriteString("<ul>\n")
+			fmt.Fprintf(b, "<li>Type: <code>%s</code></li>\n", html.EscapeString(v.typeName()))
+			if v.Multi {
+				b.WriteString("<li>Multi-valued: repeat the variable to give several values</li>\n")
+			}
+			if len(v.Default) > 0 {
+				defaults := make([]string, len(v.Default))
+				for i, value := range v.Default {
+					defaults[i] = "<code>" + html.EscapeString(value) + "</code>"
+				}
+				fmt.Fprintf(b, "<li>Default: %s</li>\n", strings.Join(defaults, ", "))
+			}
+			if allowed := v.allowedValues(); allowed != "" {
+				fmt.Fprintf(b, "<li>Allowed values: %s</li>\n", html.EscapeString(allowed))
+			}
+			b.WriteString("</ul>\n</dd>\n")
+		}
+		if len(section.Variables) > 0 {
+			b.WriteString("</dl>\n")
+		}
+		if section.ExtraValues != nil {
+			b.WriteString("<p>Any other variable may be set in this section.</p>\n")
+		}
+	}
+}
+
+// heading returns the section header line documenting the section.
+func (s *SectionSchema) heading() string {
+	if s.Subsections {
+		return "[" + s.Name + " \"<name>\"]"
+	}
+	return "[" + s.Name + "]"
+}
+
+func (v *VariableSchema) typeName() string {
+	name := v.Type.String()
+	if v.IntMode != 0 {
+		name += " (" + intModeDescription(v.IntMode) + ")"
+	}
+	return name
+}
+
+// allowedValues describes the values declared by `enum`, `min` and `max` tags.
+func (v *VariableSchema) allowedValues() string {
+	switch {
+	case len(v.Enum) > 0:
+		return strings.Join(v.Enum, ", ")
+	case v.Min != "" && v.Max != "":
+		return "from " + v.Min + " to " + v.Max
+	case v.Min != "":
+		return "at least " + v.Min
+	case v.Max != "":
+		return "at most " + v.Max
+	case v.Type.Kind() == reflect.Bool && !reflect.PtrTo(v.Type).Implements(textUnmarshalerType):
+		return "true, yes, on, 1, false, no, off, 0"
+	}
+	return ""
+}
+
+func intModeDescription(m types.IntMode) string {
+	var bases []string
+	if m&types.Dec != 0 {
+		bases = append(bases, "decimal")
+	}
+	if m&types.Hex != 0 {
+		bases = append(bases, "hexadecimal")
+	}
+	if m&types.Oct != 0 {
+		bases = append(bases, "octal")
+	}
+	if len(bases) == 1 {
+		return bases[0]
+	}
+	return strings.Join(bases[:len(bases)-1], ", ") + " or " + bases[len(bases)-1]
+}
+
+func writeSampleComment(b *bytes.Buffer, text string) {
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(text, "\n") {
+		b.WriteString(strings.TrimRight("; "+line, " ") + "\n")
+	}
+}
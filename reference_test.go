@@ -0,0 +1,141 @@
+package gcfg
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type referenceConfig struct {
+	Build struct {
+		Path    []string `help:"Directories to search for tools"`
+		Timeout int      `min:"1" help:"Timeout in seconds"`
+		Color   string   `enum:"auto,always,never"`
+		Verbose bool
+	} `help:"Controls how builds run.\nAffects all targets."`
+	Remote map[string]*struct {
+		URL string `help:"Address of the remote"`
+	}
+	Alias map[string]string
+}
+
+func newReferenceSchema(t *testing.T) *Schema {
+	s, err := SchemaOf(reflect.TypeOf(referenceConfig{}))
+	require.NoError(t, err)
+
+	config := &referenceConfig{}
+	config.Build.Path = []string{"/usr/bin", "/usr/local/bin"}
+	config.Build.Timeout = 600
+	config.Build.Color = "auto"
+	require.NoError(t, s.DefaultsFrom(config))
+	return s
+}
+
+func TestWriteReferenceMarkdown(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, newReferenceSchema(t).WriteReference(&b, Markdown))
+	assert.Equal(t, "# Configuration reference\n"+`
+## `+"`[build]`"+`
+
+Controls how builds run.
+Affects all targets.
+
+### path
+
+Directories to search for tools
+
+- Type: `+"`string`"+`
+- Multi-valued: repeat the variable to give several values
+- Default: `+"`/usr/bin`, `/usr/local/bin`"+`
+
+### timeout
+
+Timeout in seconds
+
+- Type: `+"`int (decimal or hexadecimal)`"+`
+- Default: `+"`600`"+`
+- Allowed values: at least 1
+
+### color
+
+- Type: `+"`string`"+`
+- Default: `+"`auto`"+`
+- Allowed values: auto, always, never
+
+### verbose
+
+- Type: `+"`bool`"+`
+- Default: `+"`false`"+`
+- Allowed values: true, yes, on, 1, false, no, off, 0
+
+## `+"`[remote \"<name>\"]`"+`
+
+### url
+
+Address of the remote
+
+- Type: `+"`string`"+`
+
+## `+"`[alias \"<name>\"]`"+`
+
+Any other variable may be set in this section.
+`, b.String())
+}
+
+func TestWriteReferenceHTML(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, newReferenceSchema(t).WriteReference(&b, HTML))
+	assert.Contains(t, b.String(), "<h2 id=\"remote\"><code>[remote &#34;&lt;name&gt;&#34;]</code></h2>\n")
+	assert.Contains(t, b.String(), "<dt id=\"build.path\"><code>path</code></dt>\n<dd>\n<p>Directories to search for tools</p>\n")
+	assert.Contains(t, b.String(), "<li>Default: <code>/usr/bin</code>, <code>/usr/local/bin</code></li>\n")
+
+	assert.Error(t, newReferenceSchema(t).WriteReference(&b, ReferenceFormat(-1)))
+}
+
+func TestWriteSample(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, newReferenceSchema(t).WriteSample(&b))
+	assert.Equal(t, `; Controls how builds run.
+; Affects all targets.
+[build]
+; Directories to search for tools
+; path = /usr/bin
+; path = /usr/local/bin
+
+; Timeout in seconds
+; Allowed values: at least 1
+; timeout = 600
+
+; Allowed values: auto, always, never
+; color = auto
+
+; Allowed values: true, yes, on, 1, false, no, off, 0
+; verbose = false
+
+[remote "<name>"]
+; Address of the remote
+; url =
+
+[alias "<name>"]
+; Any other variable may be set in this section.
+`, b.String())
+
+	// Uncommenting the defaults gives a valid config.
+	config := &referenceConfig{}
+	sample := strings.ReplaceAll(b.String(), "\n; path", "\npath")
+	require.NoError(t, ReadStringInto(config, sample))
+	assert.Equal(t, []string{"/usr/bin", "/usr/local/bin"}, config.Build.Path)
+	assert.Contains(t, config.Remote, "<name>")
+
+	// Empty defaults don't leave trailing spaces.
+	s, err := SchemaOf(reflect.TypeOf(struct{ Section struct{ Mode string } }{}))
+	require.NoError(t, err)
+	require.NoError(t, s.DefaultsFrom(&struct{ Section struct{ Mode string } }{}))
+	b.Reset()
+	require.NoError(t, s.WriteSample(&b))
+	assert.Equal(t, "[section]\n; mode =\n", b.String())
+}
@@ -0,0 +1,59 @@
+package scanner
+
+import (
+	"fmt"
+	"strings"
+)
+
+var escape = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", "")
+
+// Quote returns value as a gcfg variable value, quoting and escaping it if it
+// would otherwise not be read back unchanged. Carriage returns, NUL characters
+// and invalid UTF-8 can't be represented in values and are dropped.
+func Quote(value string) string {
+	value = strings.ToValidUTF8(strings.ReplaceAll(value, "\x00", ""), "")
+	if value == "" || !strings.ContainsAny(value, "\"\\;#\n\t\r") &&
+		value == strings.Trim(value, " ") {
+		return value
+	}
+	return `"` + escape.Replace(value) + `"`
+}
+
+var unescape = map[rune]rune{'\\': '\\', '"': '"', 'n': '\n', 't': '\t'}
+
+// Unquote returns the value of a subsection name or variable value literal as
+// returned by Scan, removing quotes and resolving escape sequences and line
+// continuations. It returns an error if lit is invalid; literals for which Scan
+// didn't report an error are always valid.
+func Unquote(lit string) (string, error) {
+	u, q, esc := make([]rune, 0, len(lit)), false, false
+	for _, c := range lit {
+		if esc {
+			uc, ok := unescape[c]
+			switch {
+			case ok:
+				u = append(u, uc)
+				fallthrough
+			case !q && c == '\n':
+				esc = false
+				continue
+			}
+			return "", fmt.Errorf("invalid escape sequence")
+		}
+		switch c {
+		case '"':
+			q = !q
+		case '\\':
+			esc = true
+		default:
+			u = append(u, c)
+		}
+	}
+	if q {
+		return "", fmt.Errorf("missing end quote")
+	}
+	if esc {
+		return "", fmt.Errorf("invalid escape sequence")
+	}
+	return string(u), nil
+}
@@ -0,0 +1,73 @@
+package scanner
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+var quotetests = []struct {
+	value, quoted string
+}{
+	{"", ""},
+	{"value", "value"},
+	{"two words", "two words"},
+	{"{ value }", "{ value }"},
+	{" value", `" value"`},
+	{"value ", `"value "`},
+	{"va;lue", `"va;lue"`},
+	{"va#lue", `"va#lue"`},
+	{`va"lue`, `"va\"lue"`},
+	{`va\lue`, `"va\\lue"`},
+	{"va\nlue", `"va\nlue"`},
+	{"va\tlue", `"va\tlue"`},
+	{"va\r\nlue", `"va\nlue"`},
+	{"va\x00lue", "value"},
+	{"va\xfflue", "value"},
+	{"va\x00l ue\n", `"val ue\n"`},
+}
+
+func TestQuote(t *testing.T) {
+	for _, tt := range quotetests {
+		if got := Quote(tt.value); got != tt.quoted {
+			t.Errorf("Quote(%q) = %q; want %q", tt.value, got, tt.quoted)
+		}
+	}
+}
+
+var unquotetests = []struct {
+	lit, value string
+}{
+	{"", ""},
+	{"value", "value"},
+	{`"quoted value"`, "quoted value"},
+	{`part "quoted ; part"`, "part quoted ; part"},
+	{`"va\"l\\ue"`, `va"l\ue`},
+	{`"va\nl\tue"`, "va\nl\tue"},
+	{"va\\\nlue", "value"},
+}
+
+func TestUnquote(t *testing.T) {
+	for _, tt := range unquotetests {
+		if got, err := Unquote(tt.lit); err != nil || got != tt.value {
+			t.Errorf("Unquote(%q) = %q, %v; want %q", tt.lit, got, err, tt.value)
+		}
+	}
+	for _, tt := range quotetests {
+		if strings.ContainsAny(tt.value, "\r\x00") || !utf8.ValidString(tt.value) {
+			continue // dropped by Quote
+		}
+		if got, err := Unquote(tt.quoted); err != nil || got != tt.value {
+			t.Errorf("Unquote(Quote(%q)) = %q, %v", tt.value, got, err)
+		}
+	}
+	for _, tt := range []struct{ lit, err string }{
+		{`"unterminated`, "missing end quote"},
+		{`"va\lue"`, "invalid escape sequence"},
+		{`value\`, "invalid escape sequence"},
+	} {
+		if _, err := Unquote(tt.lit); err == nil || err.Error() != tt.err {
+			t.Errorf("Unquote(%q) error = %v; want %s", tt.lit, err, tt.err)
+		}
+	}
+}
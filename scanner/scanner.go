@@ -155,6 +155,16 @@ func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9' || ch >= 0x80 && unicode.IsDigit(ch)
 }
 
+// IsIdentifier reports whether name is a valid section or variable name.
+func IsIdentifier(name string) bool {
+	for i, ch := range name {
+		if !isLetter(ch) && (i == 0 || !isDigit(ch) && ch != '-') {
+			return false
+		}
+	}
+	return name != ""
+}
+
 func (s *Scanner) scanIdentifier() string {
 	offs := s.offset
 	for isLetter(s.ch) || isDigit(s.ch) || s.ch == '-' {
@@ -400,6 +400,17 @@ func TestScanErrors(t *testing.T) {
 	}
 }
 
+func TestIsIdentifier(t *testing.T) {
+	for name, want := range map[string]bool{
+		"name": true, "Name-2": true, "ǂbar": true, "": false,
+		"2name": false, "-name": false, "a name": false, "a_name": false, "a;b": false,
+	} {
+		if got := IsIdentifier(name); got != want {
+			t.Errorf("IsIdentifier(%q) = %v; want %v", name, got, want)
+		}
+	}
+}
+
 func BenchmarkScan(b *testing.B) {
 	b.StopTimer()
 	fset := token.NewFileSet()
@@ -0,0 +1,251 @@
+package gcfg
+
+import (
+	"encoding"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/please-build/gcfg/types"
+)
+
+// Schema describes the sections and variables of a config struct type.
+type Schema struct {
+	Type     reflect.Type // the config struct type
+	Sections []*SectionSchema
+}
+
+// SectionSchema describes a section of a config.
+type SectionSchema struct {
+	Name        string              // section name, as used in gcfg files
+	Field       reflect.StructField // config struct field holding the section
+	Help        string              // description of the section, from its `help` tag
+	Subsections bool                // whether the section is a map holding subsections
+	Variables   []*VariableSchema
+	// ExtraValues describes the values of variables that aren't listed in
+	// Variables, if the section accepts them; that is, if it has a
+	// `gcfg:"extra_values"` field or is a map[string]string.
+	ExtraValues *VariableSchema
+	// DefaultSection is the name of the "default-<section>" section used to
+	// initialise new subsections, if there is one.
+	DefaultSection string
+}
+
+// VariableSchema describes a variable of a config section.
+type VariableSchema struct {
+	Name  string              // variable name, as used in gcfg files; empty for extra values
+	Field reflect.StructField // section struct field holding the variable, including its tags
+	Type  reflect.Type        // type of a single value, with unnamed pointer types dereferenced
+	Multi bool                // whether the variable is multi-valued
+	Help  string              // description of the variable, from its `help` tag
+	// IntMode is the set of bases accepted when the value is parsed as an
+	// integer, or 0 if it isn't.
+	IntMode types.IntMode
+	// Default holds the values the variable has before any gcfg data is read
+	// into the config; see Schema.DefaultsFrom.
+	Default []string
+	// Enum holds the allowed values declared by a comma-separated `enum` tag,
+	// and Min and Max the bounds declared by `min` and `max` tags. They are
+	// informational; they aren't enforced when reading gcfg data.
+	Enum     []string
+	Min, Max string
+}
+
+// SchemaOf returns the schema of the config struct type t, which must be a
+// struct type or a pointer to one.
+func SchemaOf(t reflect.Type) (*Schema, error) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Config must be a struct, instead it is of %s type", t.Kind())
+	}
+
+	s := &Schema{Type: t}
+	for i := 0; i < t.NumField(); i++ {
+		fieldStruct := t.Field(i)
+		if fieldStruct.PkgPath != "" {
+			continue
+		}
+
+		section := &SectionSchema{
+			Name:  iniKey(fieldStruct),
+			Field: fieldStruct,
+			Help:  fieldStruct.Tag.Get("help"),
+		}
+		kind, err := sectionKindOf(section.Name, fieldStruct.Type)
+		if err != nil {
+			return nil, err
+		}
+		switch kind {
+		case structSection:
+			if err := section.addVariables(fieldStruct.Type); err != nil {
+				return nil, err
+			}
+		case stringMapSection:
+			section.Subsections = true
+			section.ExtraValues = newVariableSchema("", fieldStruct)
+		case structMapSection:
+			section.Subsections = true
+			if err := section.addVariables(fieldStruct.Type.Elem().Elem()); err != nil {
+				return nil, err
+			}
+		}
+		s.Sections = append(s.Sections, section)
+	}
+
+	for _, section := range s.Sections {
+		if section.Subsections && s.Section("default-"+section.Name) != nil {
+			section.DefaultSection = "default-" + section.Name
+		}
+	}
+	return s, nil
+}
+
+// Section returns the schema of the named section, or nil if there is no
+// such section. Section names are matched ignoring case.
+func (s *Schema) Section(name string) *SectionSchema {
+	for _, section := range s.Sections {
+		if strings.EqualFold(section.Name, name) {
+			return section
+		}
+	}
+	return nil
+}
+
+// Variable returns the schema of the named variable, or nil if there is no
+// such variable. Variable names are matched ignoring case. Names that are
+// stored as extra values are not matched.
+func (s *SectionSchema) Variable(name string) *VariableSchema {
+	for _, variable := range s.Variables {
+		if strings.EqualFold(variable.Name, name) {
+			return variable
+		}
+	}
+	return nil
+}
+
+// DefaultsFrom records the values held by config, which must be a pointer to a
+// struct of the schema's type, as the defaults of the schema's variables.
+// This is typically a config that has been initialised with default values
+// but hasn't had any gcfg data read into it yet.
+//
+// The defaults of variables in sections with subsections are those of the
+// "default-<section>" section, if there is one.
+func (s *Schema) DefaultsFrom(config interface{}) error {
+	if t := reflect.TypeOf(config); t == nil || t.Kind() != reflect.Ptr || t.Elem() != s.Type {
+		return fmt.Errorf("Config must be a pointer to %s", s.Type)
+	}
+
+	for _, section := range s.Sections {
+		for _, variable := range section.Variables {
+			variable.Default = nil
+		}
+	}
+	err := Walk(config, func(section, subsection, name string, field reflect.StructField, values []string) error {
+		sectionSchema := s.Section(section)
+		if sectionSchema.Subsections {
+			return nil
+		}
+		if variable := sectionSchema.Variable(name); variable != nil && variable.Field.Name == field.Name {
+			variable.Default = values
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, section := range s.Sections {
+		if section.DefaultSection == "" {
+			continue
+		}
+		defaults := s.Section(section.DefaultSection)
+		for _, variable := range section.Variables {
+			if dflt := defaults.Variable(variable.Name); dflt != nil {
+				variable.Default = dflt.Default
+			}
+		}
+	}
+	return nil
+}
+
+func (s *SectionSchema) addVariables(t reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+		fieldStruct := t.Field(i)
+		if fieldStruct.PkgPath != "" {
+			continue
+		}
+
+		if fieldStruct.Tag.Get("gcfg") == "extra_values" {
+			if err := checkExtraValues(fieldStruct); err != nil {
+				return err
+			}
+			s.ExtraValues = newVariableSchema("", fieldStruct)
+			continue
+		}
+		v := newVariableSchema(iniKey(fieldStruct), fieldStruct)
+		for _, bound := range []string{v.Min, v.Max} {
+			if bound != "" && !isJSONNumber(bound) {
+				return fmt.Errorf("Invalid bound %q for field %s, it must be a decimal number", bound, fieldStruct.Name)
+			}
+		}
+		s.Variables = append(s.Variables, v)
+	}
+	return nil
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+func newVariableSchema(name string, fieldStruct reflect.StructField) *VariableSchema {
+	v := &VariableSchema{
+		Name:  name,
+		Field: fieldStruct,
+		Type:  fieldStruct.Type,
+		Help:  fieldStruct.Tag.Get("help"),
+		Min:   fieldStruct.Tag.Get("min"),
+		Max:   fieldStruct.Tag.Get("max"),
+	}
+	if enum := fieldStruct.Tag.Get("enum"); enum != "" {
+		v.Enum = strings.Split(enum, ",")
+	}
+	if v.Type.Kind() == reflect.Map {
+		// Extra values and map sections.
+		v.Type = v.Type.Elem()
+		if v.Type.Kind() == reflect.Slice {
+			v.Multi = true
+			v.Type = v.Type.Elem()
+		}
+		return v
+	}
+
+	if v.Type.Name() == "" && v.Type.Kind() == reflect.Ptr && v.Type.Elem().Name() == "" && v.Type.Elem().Kind() == reflect.Slice {
+		v.Type = v.Type.Elem()
+	}
+	if v.Type.Name() == "" && v.Type.Kind() == reflect.Slice {
+		v.Multi = true
+		v.Type = v.Type.Elem()
+	}
+	if v.Type.Name() == "" && v.Type.Kind() == reflect.Ptr {
+		v.Type = v.Type.Elem()
+	}
+
+	// This follows the order of `setters` in `set.go`.
+	if v.Type == reflect.TypeOf(big.Int{}) || !reflect.PtrTo(v.Type).Implements(textUnmarshalerType) && isIntKind(v.Type.Kind()) {
+		v.IntMode = intMode(newTag(fieldStruct.Tag.Get("gcfg")).intMode)
+		if v.IntMode == 0 {
+			v.IntMode = intModeDefault(v.Type)
+		}
+	}
+	return v
+}
+
+func isIntKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
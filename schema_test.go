@@ -0,0 +1,116 @@
+package gcfg
+
+import (
+	"math/big"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/please-build/gcfg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type schemaConfig struct {
+	Foo             subtypeStruct1
+	Default_Profile schemaProfile
+	Profile         map[string]*schemaProfile
+	Raw             map[string]string
+	Baz             extraValuesStruct
+}
+
+type schemaProfile struct {
+	Color    string `help:"The colour of the profile"`
+	Mode     os.FileMode
+	Octal    int `gcfg:",int=o"`
+	Multi    *[]int
+	Name     *string
+	Switch   unmarshalable
+	internal string
+}
+
+func TestSchemaOf(t *testing.T) {
+	s, err := SchemaOf(reflect.TypeOf(&schemaConfig{}))
+	require.NoError(t, err)
+	require.Equal(t, 5, len(s.Sections))
+
+	foo := s.Section("FOO")
+	require.NotNil(t, foo)
+	assert.False(t, foo.Subsections)
+	assert.Equal(t, "", foo.DefaultSection)
+	var names []string
+	for _, v := range foo.Variables {
+		names = append(names, v.Name)
+	}
+	assert.Equal(t, []string{"bar", "baz", "f--bar", "bfoo", "bazbar", "foobaz", "bar-foo", "baz-baz", "ǂbar", "xfoo"}, names)
+	assert.Equal(t, reflect.TypeOf(big.Int{}), foo.Variable("bar-foo").Type)
+	assert.Equal(t, types.Dec|types.Hex, foo.Variable("bar-foo").IntMode)
+	assert.Equal(t, reflect.TypeOf(""), foo.Variable("baz-baz").Type)
+	assert.True(t, foo.Variable("baz-baz").Multi)
+	assert.Equal(t, types.IntMode(0), foo.Variable("f--bar").IntMode)
+	assert.Equal(t, "F_Bar", foo.ExtraValues.Field.Name)
+	assert.False(t, foo.ExtraValues.Multi)
+	assert.Nil(t, foo.Variable("nonexisting"))
+
+	profile := s.Section("profile")
+	assert.True(t, profile.Subsections)
+	assert.Equal(t, "default-profile", profile.DefaultSection)
+	assert.Nil(t, profile.ExtraValues)
+	assert.Equal(t, 6, len(profile.Variables))
+	assert.Equal(t, "The colour of the profile", profile.Variable("color").Field.Tag.Get("help"))
+	assert.Equal(t, types.Dec|types.Hex|types.Oct, profile.Variable("mode").IntMode)
+	assert.Equal(t, types.Oct, profile.Variable("octal").IntMode)
+	assert.Equal(t, reflect.TypeOf(0), profile.Variable("multi").Type)
+	assert.True(t, profile.Variable("multi").Multi)
+	assert.Equal(t, reflect.TypeOf(""), profile.Variable("name").Type)
+	assert.False(t, profile.Variable("name").Multi)
+	assert.Equal(t, types.IntMode(0), profile.Variable("switch").IntMode)
+
+	raw := s.Section("raw")
+	assert.True(t, raw.Subsections)
+	assert.Empty(t, raw.Variables)
+	assert.Equal(t, reflect.TypeOf(""), raw.ExtraValues.Type)
+
+	baz := s.Section("baz")
+	assert.True(t, baz.ExtraValues.Multi)
+	assert.Equal(t, reflect.TypeOf(""), baz.ExtraValues.Type)
+}
+
+func TestSchemaOfInvalid(t *testing.T) {
+	_, err := SchemaOf(reflect.TypeOf(""))
+	assert.Error(t, err)
+
+	_, err = SchemaOf(reflect.TypeOf(struct{ Foo string }{}))
+	assert.Error(t, err)
+
+	_, err = SchemaOf(reflect.TypeOf(struct{ Foo map[string]int }{}))
+	assert.Error(t, err)
+
+	_, err = SchemaOf(reflect.TypeOf(struct {
+		Foo struct {
+			Extra []string `gcfg:"extra_values"`
+		}
+	}{}))
+	assert.Error(t, err)
+}
+
+func TestSchemaDefaultsFrom(t *testing.T) {
+	s, err := SchemaOf(reflect.TypeOf(schemaConfig{}))
+	require.NoError(t, err)
+
+	config := &schemaConfig{
+		Foo:             subtypeStruct1{Bar: "value1", Baz_Baz: []string{"value2", "value3"}},
+		Default_Profile: schemaProfile{Color: "red", Mode: 0644},
+		Profile:         map[string]*schemaProfile{"sub": {Color: "blue"}},
+	}
+	require.NoError(t, s.DefaultsFrom(config))
+
+	assert.Equal(t, []string{"value1"}, s.Section("foo").Variable("bar").Default)
+	assert.Equal(t, []string{"value2", "value3"}, s.Section("foo").Variable("baz-baz").Default)
+	assert.Equal(t, []string{"red"}, s.Section("default-profile").Variable("color").Default)
+	assert.Equal(t, []string{"red"}, s.Section("profile").Variable("color").Default)
+	assert.Equal(t, []string{"420"}, s.Section("profile").Variable("mode").Default)
+	assert.Nil(t, s.Section("profile").Variable("name").Default)
+
+	assert.Error(t, s.DefaultsFrom(&struct{}{}))
+}
@@ -18,6 +18,7 @@ import (
 type tag struct {
 	ident   string
 	intMode string
+	merge   string
 }
 
 func newTag(ts string) tag {
@@ -27,6 +28,8 @@ func newTag(ts string) tag {
 	for _, tse := range s[1:] {
 		if strings.HasPrefix(tse, "int=") {
 			t.intMode = tse[len("int="):]
+		} else if strings.HasPrefix(tse, "merge=") {
+			t.merge = tse[len("merge="):]
 		}
 	}
 	return t
@@ -214,8 +217,11 @@ func newValue(c *warnings.Collector, sect string, vCfg reflect.Value,
 	return pv, nil
 }
 
+// set stores value into the variable name of section sect and subsection sub
+// of cfg. If reset is true, any values previously held by a multi-valued
+// variable are discarded rather than appended to.
 func set(c *warnings.Collector, cfg interface{}, sect, sub, name string,
-	blank bool, value string, subsectPass bool) error {
+	blank bool, value string, subsectPass, reset bool) error {
 	//
 	vPCfg := reflect.ValueOf(cfg)
 	if vPCfg.Kind() != reflect.Ptr || vPCfg.Elem().Kind() != reflect.Struct {
@@ -282,7 +288,7 @@ func set(c *warnings.Collector, cfg interface{}, sect, sub, name string,
 	vVar, t := fieldFold(vSect, name)
 	l.variable = &name
 	if !vVar.IsValid() {
-		if ok, err := setExtraDataInSection(vSect, name, value, l); ok {
+		if ok, err := setExtraDataInSection(vSect, name, value, l, reset); ok {
 			return c.Collect(err)
 		}
 		return nil
@@ -337,6 +343,9 @@ func set(c *warnings.Collector, cfg interface{}, sect, sub, name string,
 		vVal.Set(vAddr)
 	}
 	if isMulti { // append if multi-valued
+		if reset {
+			vVar.Set(reflect.Zero(vVar.Type()))
+		}
 		vVar.Set(reflect.Append(vVar, vVal))
 	}
 	return nil
@@ -348,7 +357,7 @@ func isMultiVal(v reflect.Value) bool {
 		v.Type().Name() == "" && v.Kind() == reflect.Ptr && v.Type().Elem().Name() == "" && v.Type().Elem().Kind() == reflect.Slice
 }
 
-func setExtraDataInSection(vSect reflect.Value, key, value string, loc loc) (bool, error) {
+func setExtraDataInSection(vSect reflect.Value, key, value string, loc loc, reset bool) (bool, error) {
 	if extraDataField := findExtraDataField(vSect); extraDataField == nil {
 		return true, extraData{loc: loc}
 	} else if extraDataField.Type() == reflect.TypeOf(map[string]string{}) {
@@ -363,7 +372,7 @@ func setExtraDataInSection(vSect reflect.Value, key, value string, loc loc) (boo
 			extraDataField.Set(reflect.ValueOf(map[string][]string{key: {value}}))
 			return false, extraData{}
 		}
-		if v := extraDataField.MapIndex(reflect.ValueOf(key)); v.IsValid() {
+		if v := extraDataField.MapIndex(reflect.ValueOf(key)); v.IsValid() && !reset {
 			vs := append(v.Interface().([]string), value)
 			extraDataField.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(vs))
 			return false, extraData{}
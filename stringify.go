@@ -11,106 +11,28 @@ import (
 	"unicode/utf8"
 )
 
-// Stringify returns the ini format representation of `config`.
+// Stringify returns the ini format representation of `config`. Sections and
+// variables are written in the order Walk visits them.
 func Stringify(config interface{}) (string, error) {
-	configPtr := reflect.ValueOf(config)
-	if configPtr.Kind() != reflect.Ptr || configPtr.Elem().Kind() != reflect.Struct {
-		return "", fmt.Errorf("Config must be a pointer to a struct")
-	}
-	configValue := configPtr.Elem()
-
 	var s string
-	for i := 0; i < configValue.NumField(); i++ {
-		fieldValue := configValue.Field(i)
-		fieldStruct := configValue.Type().Field(i)
-		if !fieldValue.CanInterface() {
-			continue
-		}
-
-		iniFieldName := iniKey(fieldStruct)
-
-		if fieldValue.Kind() == reflect.Struct {
-			iniVariableLines, err := stringifyStructFields(fieldValue)
-			if err != nil {
-				return "", err
-			}
-
-			s += iniSectionLine(iniFieldName, "")
-			s += iniVariableLines
+	err := walk(config, true, func(section, subsection string, kind sectionKind) error {
+		if s != "" {
 			s += "\n"
-		} else if fieldValue.Kind() == reflect.Map {
-			if fieldStruct.Type.Key().Kind() != reflect.String {
-				return "", fmt.Errorf("The map keys must to be of string type, instead they are of %s type", fieldStruct.Type.Key().Kind())
-			}
-
-			if fieldStruct.Type.Elem().Kind() == reflect.String {
-				for subsection, variables := range decodeStringMap(fieldValue) {
-					s += iniSectionLine(iniFieldName, subsection)
-					for variable, value := range variables {
-						s += iniVariableLine(variable, value)
-					}
-					s += "\n"
-				}
-			} else if fieldStruct.Type.Elem().Kind() == reflect.Ptr && fieldStruct.Type.Elem().Elem().Kind() == reflect.Struct {
-				iter := fieldValue.MapRange()
-				for iter.Next() {
-					iniVariableLines, err := stringifyStructFields(iter.Value().Elem())
-					if err != nil {
-						return "", err
-					}
-
-					s += iniSectionLine(iniFieldName, iter.Key().String())
-					s += iniVariableLines
-					s += "\n"
-				}
-			} else {
-				return "", fmt.Errorf("The map values must either be of string or *struct type, instead they are of %s type", fieldStruct.Type.Elem().Kind())
-			}
-		}
-	}
-
-	return s, nil
-}
-
-func stringifyStructFields(value reflect.Value) (string, error) {
-	if value.Kind() != reflect.Struct {
-		return "", fmt.Errorf("Expected a struct type from %v, but instead got %s\n", value, value.Kind())
-	}
-
-	var s string
-	for i := 0; i < value.NumField(); i++ {
-		fieldValue := value.Field(i)
-		fieldStruct := value.Type().Field(i)
-		if !fieldValue.CanInterface() {
-			continue
 		}
-
-		if fieldStruct.Tag.Get("gcfg") == "extra_values" {
-			if fieldStruct.Type != reflect.TypeOf(map[string]string{}) && fieldStruct.Type != reflect.TypeOf(map[string][]string{}) {
-				return "", fmt.Errorf("Expected either a map[string]string or map[string][]string type, but instead got %s\n", fieldStruct.Type)
-			}
-
-			iter := fieldValue.MapRange()
-			for iter.Next() {
-				iterateMaybeSlice(iter.Value(), func(innerValue reflect.Value) error {
-					s += iniVariableLine(iter.Key().String(), innerValue.String())
-					return nil
-				})
-			}
-		} else {
-			if err := iterateMaybeSlice(fieldValue, func(innerValue reflect.Value) error {
-				res, err := iniValue(innerValue)
-				if err != nil {
-					return err
-				}
-				s += iniVariableLine(iniKey(fieldStruct), res)
-				return nil
-			}); err != nil {
-				return "", err
-			}
+		s += iniSectionLine(section, subsection)
+		return nil
+	}, iniVisitor(func(section, subsection, name string, field reflect.StructField, values []string) error {
+		for _, value := range values {
+			s += iniVariableLine(name, value)
 		}
+		return nil
+	}))
+	if err != nil {
+		return "", err
+	}
+	if s != "" {
+		s += "\n"
 	}
-
 	return s, nil
 }
 
@@ -190,18 +112,13 @@ func decodeStringMap(value reflect.Value) map[string]map[string]string {
 	mapTree := make(map[string]map[string]string)
 	iter := value.MapRange()
 	for iter.Next() {
-		parts := strings.Split(iter.Key().String(), " ")
-		if len(parts) > 2 {
-			panic("Invalid map key value")
-		}
-
+		// Subsection names may contain spaces, but variable names can't.
+		key := iter.Key().String()
 		var subsection string
-		var variable string
-		if len(parts) == 1 {
-			variable = parts[0]
-		} else {
-			subsection = parts[0]
-			variable = parts[1]
+		variable := key
+		if i := strings.LastIndex(key, " "); i >= 0 {
+			subsection = key[:i]
+			variable = key[i+1:]
 		}
 		if _, exists := mapTree[subsection]; !exists {
 			mapTree[subsection] = make(map[string]string)
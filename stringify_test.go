@@ -156,6 +156,45 @@ key = value
 	assert.Equal(t, res, expectedResult)
 }
 
+func TestStringifyOrder(t *testing.T) {
+	config := &struct {
+		Skipped int
+		Foo     map[string]*subtypeStructNoMarshaler
+		Bar     map[string]string
+		Baz     extraValuesStruct
+	}{
+		Foo: map[string]*subtypeStructNoMarshaler{"b": {Value: "2"}, "a": {Value: "1"}, "nil": nil},
+		Bar: map[string]string{"y": "2", "x": "1", "sub z": "3"},
+		Baz: extraValuesStruct{ExtraValues: map[string][]string{"b": {"2"}, "a": {"1"}}},
+	}
+	expectedResult := `[foo "a"]
+value = 1
+
+[foo "b"]
+value = 2
+
+[bar]
+x = 1
+y = 2
+
+[bar "sub"]
+z = 3
+
+[baz]
+a = 1
+b = 2
+
+`
+
+	res, err := Stringify(config)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedResult, res)
+
+	raw, err := RawJSON(config)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"foo":{"a":{"value":"1"},"b":{"value":"2"}},"bar":{"x":"1","y":"2","sub z":"3"},"baz":{"a":["1"],"b":["2"]}}`, string(raw))
+}
+
 func TestStringify6(t *testing.T) {
 	config := &struct {
 		Bar subtypeStructNoMarshaler
@@ -0,0 +1,128 @@
+package gcfg
+
+import (
+	"fmt"
+	"reflect"
+
+	"gopkg.in/warnings.v0"
+)
+
+// Set sets the value of a config field. The value is parsed the same way as
+// values read from a gcfg file are, and any values previously held by a
+// multi-valued field are replaced.
+//
+// As when reading, subsections that don't exist yet are created and
+// initialised from the "default-<section>" section if there is one, and names
+// that don't match a field are stored in the section's `gcfg:"extra_values"`
+// field if it has one.
+func Set(config interface{}, section, subsection, name, value string) error {
+	return update(config, section, subsection, name, value, true)
+}
+
+// Add adds a value to a config field. For multi-valued fields the value is
+// appended to any existing values; otherwise Add behaves the same as Set.
+func Add(config interface{}, section, subsection, name, value string) error {
+	return update(config, section, subsection, name, value, false)
+}
+
+// Unset resets a config field to its zero value. Values stored in map
+// sections or in `gcfg:"extra_values"` fields are deleted.
+func Unset(config interface{}, section, subsection, name string) error {
+	field, err := parseField(section, subsection, name)
+	if err != nil {
+		return err
+	}
+
+	configPtr := reflect.ValueOf(config)
+	if configPtr.Kind() != reflect.Ptr || configPtr.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Config must be a pointer to a struct")
+	}
+	configValue := configPtr.Elem()
+
+	sectionValue, _ := fieldFold(configValue, section)
+	if !sectionValue.IsValid() {
+		return fmt.Errorf("Section does not exist: %s", section)
+	}
+
+	if sectionValue.Kind() == reflect.Map {
+		if sectionValue.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("Invalid unsettable field: %s", field)
+		}
+
+		if sectionValue.Type().Elem().Kind() == reflect.String {
+			// See decodeStringMap for how subsections are encoded in map keys.
+			key := name
+			if subsection != "" {
+				key = subsection + " " + name
+			}
+			return deleteMapIndex(sectionValue, key, field)
+		}
+		if sectionValue.Type().Elem().Kind() != reflect.Ptr || sectionValue.Type().Elem().Elem().Kind() != reflect.Struct {
+			return fmt.Errorf("Invalid unsettable field: %s", field)
+		}
+		res := sectionValue.MapIndex(reflect.ValueOf(subsection))
+		if !res.IsValid() {
+			return fmt.Errorf("Settable field not defined: %s", field)
+		}
+		sectionValue = res.Elem()
+	} else if subsection != "" {
+		return fmt.Errorf("Subsection does not exist: %s", subsection)
+	} else if sectionValue.Kind() != reflect.Struct {
+		return fmt.Errorf("Invalid unsettable field: %s", field)
+	}
+
+	variableValue, _ := fieldFold(sectionValue, name)
+	if variableValue.IsValid() {
+		variableValue.Set(reflect.Zero(variableValue.Type()))
+		return nil
+	}
+
+	extraDataValue := findExtraDataField(sectionValue)
+	if extraDataValue == nil || extraDataValue.Kind() != reflect.Map {
+		return fmt.Errorf("Invalid unsettable field: %s", field)
+	}
+	return deleteMapIndex(*extraDataValue, name, field)
+}
+
+func update(config interface{}, section, subsection, name, value string, reset bool) error {
+	field, err := parseField(section, subsection, name)
+	if err != nil {
+		return err
+	}
+
+	configPtr := reflect.ValueOf(config)
+	if configPtr.Kind() != reflect.Ptr || configPtr.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Config must be a pointer to a struct")
+	}
+
+	sectionValue, _ := fieldFold(configPtr.Elem(), section)
+	if sectionValue.IsValid() && !isSectionField(sectionValue) {
+		return fmt.Errorf("Invalid unsettable field: %s", field)
+	}
+
+	// Only one of the two passes used when reading applies to any one
+	// section, so there's no need to run both of them.
+	subsectPass := sectionValue.Kind() == reflect.Map
+
+	c := warnings.NewCollector(isFatal)
+	if err := set(c, config, section, subsection, name, false, value, subsectPass, reset); err != nil {
+		return err
+	}
+	return c.Done()
+}
+
+// isSectionField reports whether v, a top-level config field, can hold a
+// section. set panics on fields that can't, so callers must check them first.
+func isSectionField(v reflect.Value) bool {
+	_, err := sectionKindOf("", v.Type())
+	return err == nil
+}
+
+func deleteMapIndex(mapValue reflect.Value, key, field string) error {
+	k := reflect.ValueOf(key)
+	if !mapValue.MapIndex(k).IsValid() {
+		return fmt.Errorf("Settable field not defined: %s", field)
+	}
+	mapValue.SetMapIndex(k, reflect.Value{})
+	return nil
+}
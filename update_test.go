@@ -0,0 +1,154 @@
+package gcfg
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetInvalidFields(t *testing.T) {
+	config := &struct {
+		Foo subtypeStructNoMarshaler
+	}{}
+
+	assert.Error(t, Set(config, "", "", "value", "value"))
+	assert.Error(t, Set(config, "nosection", "", "value", "value"))
+	assert.Error(t, Set(config, "foo", "", "novar", "value"))
+	assert.Error(t, Set(config, "foo", "sub", "value", "value"))
+	assert.Error(t, Set(*config, "foo", "", "value", "value"))
+
+	unsupported := &struct {
+		Num    int
+		IntKey map[int]*subtypeStructNoMarshaler
+		IntMap map[string]int
+	}{}
+	for _, tt := range []struct{ section, subsection, field string }{
+		{"num", "", "num.value"},
+		{"intkey", "sub", "intkey.sub.value"},
+		{"intmap", "sub", "intmap.sub.value"},
+	} {
+		msg := "Invalid unsettable field: " + tt.field
+		assert.EqualError(t, Set(unsupported, tt.section, tt.subsection, "value", "1"), msg)
+		assert.EqualError(t, Add(unsupported, tt.section, tt.subsection, "value", "1"), msg)
+		assert.EqualError(t, Unset(unsupported, tt.section, tt.subsection, "value"), msg)
+	}
+}
+
+func TestSet1(t *testing.T) {
+	config := &struct {
+		Foo subtypeStruct1
+	}{
+		Foo: subtypeStruct1{
+			Baz_Baz: []string{"value3", "value4"},
+		},
+	}
+
+	assert.NoError(t, Set(config, "foo", "", "bar", "value1"))
+	assert.Equal(t, "value1", config.Foo.Bar)
+
+	assert.NoError(t, Set(config, "foo", "", "f--bar", "yes"))
+	assert.True(t, config.Foo.FBar)
+
+	assert.NoError(t, Set(config, "foo", "", "foobaz", "0x10"))
+	assert.Equal(t, 16, config.Foo.FooBaz)
+
+	assert.NoError(t, Set(config, "foo", "", "bar-foo", "10"))
+	assert.Equal(t, big.NewInt(10), config.Foo.Bar_Foo)
+
+	assert.NoError(t, Set(config, "foo", "", "baz-baz", "value5"))
+	assert.Equal(t, []string{"value5"}, config.Foo.Baz_Baz)
+
+	assert.NoError(t, Set(config, "foo", "", "bar-key", "bar-value"))
+	assert.Equal(t, map[string]string{"bar-key": "bar-value"}, config.Foo.F_Bar)
+
+	// Values that fail to parse leave the field untouched.
+	assert.Error(t, Set(config, "foo", "", "bazbar", "-1"))
+	assert.Equal(t, uint(0), config.Foo.Bazbar)
+}
+
+func TestSet2(t *testing.T) {
+	config := &struct {
+		Default_Foo subtypeStruct1
+		Foo         map[string]*subtypeStruct1
+	}{
+		Default_Foo: subtypeStruct1{Bar: "default", FooBaz: 7},
+	}
+
+	assert.NoError(t, Set(config, "foo", "sub", "bar", "value1"))
+	assert.Equal(t, "value1", config.Foo["sub"].Bar)
+	assert.Equal(t, 7, config.Foo["sub"].FooBaz)
+	assert.Equal(t, "default", config.Default_Foo.Bar)
+}
+
+func TestSet3(t *testing.T) {
+	config := &struct {
+		Foo map[string]string
+	}{}
+
+	assert.NoError(t, Set(config, "foo", "", "key", "value"))
+	assert.NoError(t, Set(config, "foo", "sub", "key", "value2"))
+	assert.Equal(t, map[string]string{"key": "value", "sub key": "value2"}, config.Foo)
+}
+
+func TestAdd(t *testing.T) {
+	config := &struct {
+		Foo subtypeStruct1
+		Baz extraValuesStruct
+	}{
+		Foo: subtypeStruct1{
+			Bar:     "value1",
+			Baz_Baz: []string{"value3"},
+		},
+	}
+
+	assert.NoError(t, Add(config, "foo", "", "baz-baz", "value4"))
+	assert.Equal(t, []string{"value3", "value4"}, config.Foo.Baz_Baz)
+
+	assert.NoError(t, Add(config, "foo", "", "bar", "value2"))
+	assert.Equal(t, "value2", config.Foo.Bar)
+
+	assert.NoError(t, Add(config, "baz", "", "key1", "value5"))
+	assert.NoError(t, Add(config, "baz", "", "key1", "value6"))
+	assert.Equal(t, map[string][]string{"key1": {"value5", "value6"}}, config.Baz.ExtraValues)
+
+	assert.NoError(t, Set(config, "baz", "", "key1", "value7"))
+	assert.Equal(t, map[string][]string{"key1": {"value7"}}, config.Baz.ExtraValues)
+}
+
+func TestUnset(t *testing.T) {
+	config := &struct {
+		Foo subtypeStruct1
+		Bar map[string]*subtypeStruct1
+		Baz map[string]string
+	}{
+		Foo: subtypeStruct1{
+			Bar:     "value1",
+			Baz_Baz: []string{"value3", "value4"},
+			F_Bar:   map[string]string{"bar-key": "bar-value"},
+		},
+		Bar: map[string]*subtypeStruct1{"sub": {Bar: "value2"}},
+		Baz: map[string]string{"sub key": "value"},
+	}
+
+	assert.NoError(t, Unset(config, "foo", "", "bar"))
+	assert.Equal(t, "", config.Foo.Bar)
+
+	assert.NoError(t, Unset(config, "foo", "", "baz-baz"))
+	assert.Nil(t, config.Foo.Baz_Baz)
+
+	assert.NoError(t, Unset(config, "foo", "", "bar-key"))
+	assert.Empty(t, config.Foo.F_Bar)
+	assert.Error(t, Unset(config, "foo", "", "bar-key"))
+
+	assert.NoError(t, Unset(config, "bar", "sub", "bar"))
+	assert.Equal(t, "", config.Bar["sub"].Bar)
+	assert.Error(t, Unset(config, "bar", "nosub", "bar"))
+
+	assert.NoError(t, Unset(config, "baz", "sub", "key"))
+	assert.Empty(t, config.Baz)
+	assert.Error(t, Unset(config, "baz", "sub", "key"))
+
+	assert.Error(t, Unset(config, "nosection", "", "bar"))
+	assert.Error(t, Unset(config, "foo", "", "novar"))
+}
@@ -0,0 +1,239 @@
+package gcfg
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// WalkFunc is the type of the function called by Walk for each variable of a
+// config.
+//
+// field is the struct field the variable is stored in; for variables stored in
+// map sections or in `gcfg:"extra_values"` fields it is the map field. values
+// holds the ini representation of the variable's values, as returned by Get.
+type WalkFunc func(section, subsection, name string, field reflect.StructField, values []string) error
+
+// Walk calls fn for every settable variable of config, which must be a pointer
+// to a struct.
+//
+// Sections and variables are visited in struct field order, and subsections
+// and extra values are visited sorted by name, so the order is deterministic.
+// Single-valued variables holding a nil pointer are visited with no values.
+// If fn returns an error, Walk stops and returns that error.
+func Walk(config interface{}, fn WalkFunc) error {
+	return walk(config, false, nil, iniVisitor(fn))
+}
+
+// sectionKind is the way a top-level config field holds a section.
+type sectionKind int
+
+const (
+	structSection    sectionKind = iota // a struct
+	stringMapSection                    // a map[string]string; see decodeStringMap
+	structMapSection                    // a map of subsection names to struct pointers
+)
+
+// sectionKindOf returns the kind of section held by a top-level config field
+// of type t, or an error if it can't hold one.
+func sectionKindOf(section string, t reflect.Type) (sectionKind, error) {
+	switch t.Kind() {
+	case reflect.Struct:
+		return structSection, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return 0, fmt.Errorf("The map keys must be of string type, instead they are of %s type", t.Key().Kind())
+		}
+		if t.Elem().Kind() == reflect.String {
+			return stringMapSection, nil
+		}
+		if t.Elem().Kind() == reflect.Ptr && t.Elem().Elem().Kind() == reflect.Struct {
+			return structMapSection, nil
+		}
+		return 0, fmt.Errorf("The map values must either be of string or *struct type, instead they are of %s type", t.Elem().Kind())
+	}
+	return 0, fmt.Errorf("Section %s must either be of struct or map type, instead it is of %s type", section, t.Kind())
+}
+
+// checkExtraValues returns an error if the `gcfg:"extra_values"` field
+// fieldStruct doesn't have a supported type.
+func checkExtraValues(fieldStruct reflect.StructField) error {
+	if fieldStruct.Type != reflect.TypeOf(map[string]string{}) && fieldStruct.Type != reflect.TypeOf(map[string][]string{}) {
+		return fmt.Errorf("Expected either a map[string]string or map[string][]string type, but instead got %s\n", fieldStruct.Type)
+	}
+	return nil
+}
+
+// visitFunc is the type of the function called by walk for each variable. value
+// is the struct field holding the variable, or the value stored for it in a map
+// section or `gcfg:"extra_values"` field, which is a string or a []string.
+type visitFunc func(section, subsection, name string, field reflect.StructField, value reflect.Value) error
+
+// walk visits the variables of config in the same order as Walk. sectionFn, if
+// not nil, is called before the variables of each section and subsection,
+// including those that don't have any. Top-level fields that can't hold a
+// section are skipped if lenient is true, and are an error otherwise.
+func walk(config interface{}, lenient bool, sectionFn func(section, subsection string, kind sectionKind) error, fn visitFunc) error {
+	configPtr := reflect.ValueOf(config)
+	if configPtr.Kind() != reflect.Ptr || configPtr.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Config must be a pointer to a struct")
+	}
+	configValue := configPtr.Elem()
+	if sectionFn == nil {
+		sectionFn = func(section, subsection string, kind sectionKind) error { return nil }
+	}
+
+	for i := 0; i < configValue.NumField(); i++ {
+		fieldValue := configValue.Field(i)
+		fieldStruct := configValue.Type().Field(i)
+		if !fieldValue.CanInterface() {
+			continue
+		}
+		if k := fieldValue.Kind(); lenient && k != reflect.Struct && k != reflect.Map {
+			continue
+		}
+
+		section := iniKey(fieldStruct)
+		kind, err := sectionKindOf(section, fieldStruct.Type)
+		if err != nil {
+			return err
+		}
+
+		switch kind {
+		case structSection:
+			if err := sectionFn(section, "", kind); err != nil {
+				return err
+			}
+			if err := walkSection(section, "", fieldValue, fn); err != nil {
+				return err
+			}
+		case stringMapSection:
+			tree := decodeStringMap(fieldValue)
+			for _, subsection := range sortedKeys(reflect.ValueOf(tree)) {
+				if err := sectionFn(section, subsection, kind); err != nil {
+					return err
+				}
+				variables := tree[subsection]
+				for _, name := range sortedKeys(reflect.ValueOf(variables)) {
+					if err := fn(section, subsection, name, fieldStruct, reflect.ValueOf(variables[name])); err != nil {
+						return err
+					}
+				}
+			}
+		case structMapSection:
+			for _, subsection := range sortedKeys(fieldValue) {
+				subsectionValue := fieldValue.MapIndex(reflect.ValueOf(subsection).Convert(fieldStruct.Type.Key()))
+				if subsectionValue.IsNil() {
+					continue
+				}
+				if err := sectionFn(section, subsection, kind); err != nil {
+					return err
+				}
+				if err := walkSection(section, subsection, subsectionValue.Elem(), fn); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func walkSection(section, subsection string, value reflect.Value, fn visitFunc) error {
+	for i := 0; i < value.NumField(); i++ {
+		fieldValue := value.Field(i)
+		fieldStruct := value.Type().Field(i)
+		if !fieldValue.CanInterface() {
+			continue
+		}
+
+		if fieldStruct.Tag.Get("gcfg") == "extra_values" {
+			if err := checkExtraValues(fieldStruct); err != nil {
+				return err
+			}
+			for _, name := range sortedKeys(fieldValue) {
+				if err := fn(section, subsection, name, fieldStruct, fieldValue.MapIndex(reflect.ValueOf(name))); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if err := fn(section, subsection, iniKey(fieldStruct), fieldStruct, fieldValue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// iniVisitor returns a visitFunc that calls fn with the ini representation of
+// the values of each variable.
+func iniVisitor(fn WalkFunc) visitFunc {
+	return func(section, subsection, name string, field reflect.StructField, value reflect.Value) error {
+		var values []string
+		if field.Type.Kind() == reflect.Map {
+			iterateMaybeSlice(value, func(innerValue reflect.Value) error {
+				values = append(values, innerValue.String())
+				return nil
+			})
+		} else {
+			var err error
+			if values, err = iniValues(value); err != nil {
+				return fmt.Errorf("Failed to retrieve field %s: %s", name, err)
+			}
+		}
+		return fn(section, subsection, name, field, values)
+	}
+}
+
+// iniValues returns the ini representation of the values held by a variable.
+// Unnamed pointer types are dereferenced the same way `set` does, and nil
+// pointers are treated as holding no value.
+func iniValues(value reflect.Value) ([]string, error) {
+	if !isMultiVal(value) {
+		return iniPtrValue(value, nil)
+	}
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil, nil
+		}
+		value = value.Elem()
+	}
+
+	values := make([]string, 0, value.Len())
+	for i := 0; i < value.Len(); i++ {
+		var err error
+		if values, err = iniPtrValue(value.Index(i), values); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// iniPtrValue appends the ini representation of value to values, unless value
+// is a nil pointer.
+func iniPtrValue(value reflect.Value, values []string) ([]string, error) {
+	if value.Type().Name() == "" && value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return values, nil
+		}
+		if _, ok := value.Interface().(encoding.TextMarshaler); !ok {
+			value = value.Elem()
+		}
+	}
+	res, err := iniValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return append(values, res), nil
+}
+
+// sortedKeys returns the keys of a map with string keys in sorted order.
+func sortedKeys(value reflect.Value) []string {
+	keys := make([]string, 0, value.Len())
+	for _, k := range value.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,122 @@
+package gcfg
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type walkVisit struct {
+	section, subsection, name, field string
+	values                           []string
+}
+
+func walkAll(config interface{}) ([]walkVisit, error) {
+	var visits []walkVisit
+	err := Walk(config, func(section, subsection, name string, field reflect.StructField, values []string) error {
+		visits = append(visits, walkVisit{section, subsection, name, field.Name, values})
+		return nil
+	})
+	return visits, err
+}
+
+func TestWalk1(t *testing.T) {
+	config := &struct {
+		Foo subtypeStruct1
+		Bar map[string]*subtypeStructNoMarshaler
+		Baz map[string]string
+	}{
+		Foo: subtypeStruct1{
+			Bar:     "value1",
+			Baz:     subtypeStructWithMarshaler{Value: "value2"},
+			FBar:    true,
+			FooBaz:  -5,
+			Bar_Foo: big.NewInt(10),
+			Baz_Baz: []string{"value3", "value4"},
+			F_Bar:   map[string]string{"b-key": "b-value", "a-key": "a-value"},
+		},
+		Bar: map[string]*subtypeStructNoMarshaler{
+			"sub2": {Value: "value6"},
+			"sub1": {Value: "value5"},
+		},
+		Baz: map[string]string{"key": "value7", "sub with spaces key": "value8"},
+	}
+
+	visits, err := walkAll(config)
+	assert.NoError(t, err)
+	assert.Equal(t, []walkVisit{
+		{"foo", "", "bar", "Bar", []string{"value1"}},
+		{"foo", "", "baz", "Baz", []string{"value2"}},
+		{"foo", "", "f--bar", "FBar", []string{"true"}},
+		{"foo", "", "bfoo", "BFoo", []string{"false"}},
+		{"foo", "", "bazbar", "Bazbar", []string{"0"}},
+		{"foo", "", "foobaz", "FooBaz", []string{"-5"}},
+		{"foo", "", "bar-foo", "Bar_Foo", []string{"10"}},
+		{"foo", "", "baz-baz", "Baz_Baz", []string{"value3", "value4"}},
+		{"foo", "", "a-key", "F_Bar", []string{"a-value"}},
+		{"foo", "", "b-key", "F_Bar", []string{"b-value"}},
+		{"foo", "", "ǂbar", "Xǂbar", []string{""}},
+		{"foo", "", "xfoo", "Xfoo", []string{""}},
+		{"bar", "sub1", "value", "Value", []string{"value5"}},
+		{"bar", "sub2", "value", "Value", []string{"value6"}},
+		{"baz", "", "key", "Baz", []string{"value7"}},
+		{"baz", "sub with spaces", "key", "Baz", []string{"value8"}},
+	}, visits)
+}
+
+func TestWalk2(t *testing.T) {
+	config := &struct {
+		Section struct {
+			Name     *string
+			Unset    *string
+			Multi    []string
+			PMulti   *[]string
+			MultiBig []*big.Int
+		}
+		Extra extraValuesStruct
+	}{}
+	config.Section.Name = newString("value1")
+	config.Section.PMulti = newStringSlice("value2")
+	config.Section.MultiBig = []*big.Int{big.NewInt(1), nil, big.NewInt(2)}
+	config.Extra.ExtraValues = map[string][]string{"key1": {"value3", "value4"}}
+
+	visits, err := walkAll(config)
+	assert.NoError(t, err)
+	assert.Equal(t, []walkVisit{
+		{"section", "", "name", "Name", []string{"value1"}},
+		{"section", "", "unset", "Unset", nil},
+		{"section", "", "multi", "Multi", []string{}},
+		{"section", "", "pmulti", "PMulti", []string{"value2"}},
+		{"section", "", "multibig", "MultiBig", []string{"1", "2"}},
+		{"extra", "", "key1", "ExtraValues", []string{"value3", "value4"}},
+	}, visits)
+}
+
+func TestWalkErrors(t *testing.T) {
+	config := &struct {
+		Foo subtypeStructNoMarshaler
+		Bar string
+	}{}
+
+	_, err := walkAll(config)
+	assert.Error(t, err, "Top-level fields must be sections")
+
+	_, err = walkAll(&struct{ Foo subtypeStruct2 }{})
+	assert.Error(t, err, "Variables must be convertible to strings")
+
+	_, err = walkAll(&struct{ Foo map[int]string }{})
+	assert.EqualError(t, err, "The map keys must be of string type, instead they are of int type")
+	_, err = walkAll(&struct{ Foo map[string]int }{})
+	assert.EqualError(t, err, "The map values must either be of string or *struct type, instead they are of int type")
+
+	calls := 0
+	err = Walk(&struct{ Foo subtypeStruct1 }{}, func(section, subsection, name string, field reflect.StructField, values []string) error {
+		calls++
+		return fmt.Errorf("stop")
+	})
+	assert.EqualError(t, err, "stop")
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,210 @@
+package gcfg
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A Watcher holds a config read from a file, and reloads it when the file
+// changes.
+//
+// Changes are detected by polling the file's modification time and size, and
+// then comparing a hash of its contents, so no platform-specific file system
+// notifications are needed. Each reload reads the file into a new config,
+// which replaces the current one atomically; configs returned by Load are
+// never modified. If a reload fails, the current config is kept.
+type Watcher[T any] struct {
+	// OnError, if not nil, is called by Run with the errors of failed
+	// reloads.
+	OnError func(err error)
+
+	filename string
+	init     func(*T)
+	config   atomic.Pointer[T]
+
+	mu    sync.Mutex // serialises reloads, and guards state
+	state fileState
+
+	subscribersMu      sync.Mutex
+	subscribers        []func(old, new *T)
+	sectionSubscribers []sectionSubscriber[T]
+}
+
+type sectionSubscriber[T any] struct {
+	section, subsection string
+	fn                  func(old, new *T)
+}
+
+// fileState identifies the contents of a file.
+type fileState struct {
+	modTime time.Time
+	size    int64
+	hash    [sha256.Size]byte
+}
+
+// NewWatcher returns a Watcher for the file filename, with its config loaded
+// from the file. init, if not nil, is called with each new config before the
+// file is read into it, e.g. to set defaults.
+//
+// As with ReadFileInto, warnings for data that doesn't belong to any part of
+// the config are errors, so they cause the load to fail. T must be a struct
+// type.
+func NewWatcher[T any](filename string, init func(*T)) (*Watcher[T], error) {
+	if t := reflect.TypeOf((*T)(nil)).Elem(); t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Config must be a struct, instead it is of %s type", t.Kind())
+	}
+	w := &Watcher[T]{filename: filename, init: init}
+	if err := w.Reload(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Load returns the current config. It must not be modified.
+func (w *Watcher[T]) Load() *T {
+	return w.config.Load()
+}
+
+// Subscribe registers fn to be called with the old and new configs each time
+// the config is reloaded. Subscribers are called in the order they were
+// registered, from the goroutine that reloaded the config, and must not call
+// Reload or Check.
+func (w *Watcher[T]) Subscribe(fn func(old, new *T)) {
+	w.subscribersMu.Lock()
+	defer w.subscribersMu.Unlock()
+	w.subscribers = append(w.subscribers, fn)
+}
+
+// SubscribeSection registers fn to be called with the old and new configs
+// when the config is reloaded and the named section or subsection has
+// changed. subsection must be empty for sections that don't have subsections.
+// For sections that do, an empty subsection subscribes to the whole section,
+// so fn is called when any of its subsections changes, including the one
+// given by a heading without a subsection name. Adding or removing a
+// subsection counts as a change.
+//
+// Sections are compared structurally, as with reflect.DeepEqual, so a reload
+// that only changes other sections doesn't call fn. Section subscribers are
+// called after those registered with Subscribe, in the order they were
+// registered, and have the same restrictions.
+func (w *Watcher[T]) SubscribeSection(section, subsection string, fn func(old, new *T)) error {
+	sectionField, _ := fieldFold(reflect.ValueOf(new(T)).Elem(), section)
+	if !sectionField.IsValid() {
+		return fmt.Errorf("Section does not exist: %s", section)
+	}
+	if sectionField.Kind() != reflect.Map && subsection != "" {
+		return fmt.Errorf("Section %s does not have subsections", section)
+	}
+
+	w.subscribersMu.Lock()
+	defer w.subscribersMu.Unlock()
+	w.sectionSubscribers = append(w.sectionSubscribers, sectionSubscriber[T]{section, subsection, fn})
+	return nil
+}
+
+// Reload reads the file into a new config, whether it has changed or not, and
+// notifies subscribers. If reading fails, the current config is kept and the
+// error is returned.
+func (w *Watcher[T]) Reload() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.reload(true)
+	return err
+}
+
+// Check reloads the config if the file has changed since it was last read,
+// and reports whether it was reloaded. If reading fails, the current config is
+// kept and the error is returned; the file isn't read again until it changes.
+func (w *Watcher[T]) Check() (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.reload(false)
+}
+
+// Run calls Check every interval, until ctx is done, and then returns
+// ctx.Err(). Errors are passed to OnError.
+func (w *Watcher[T]) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if _, err := w.Check(); err != nil && w.OnError != nil {
+				w.OnError(err)
+			}
+		}
+	}
+}
+
+// reload reads the file into a new config if force is true or the file has
+// changed. w.mu must be held.
+func (w *Watcher[T]) reload(force bool) (bool, error) {
+	info, err := os.Stat(w.filename)
+	if err != nil {
+		return false, err
+	}
+	if !force && info.ModTime().Equal(w.state.modTime) && info.Size() == w.state.size {
+		return false, nil
+	}
+	src, err := os.ReadFile(w.filename)
+	if err != nil {
+		return false, err
+	}
+	state := fileState{modTime: info.ModTime(), size: info.Size(), hash: sha256.Sum256(src)}
+	unchanged := state.hash == w.state.hash
+	w.state = state
+	if !force && unchanged {
+		return false, nil
+	}
+
+	config := new(T)
+	if w.init != nil {
+		w.init(config)
+	}
+	if err := readFileInto(config, w.filename, src); err != nil {
+		return false, err
+	}
+	old := w.config.Swap(config)
+
+	w.subscribersMu.Lock()
+	subscribers := w.subscribers
+	sectionSubscribers := w.sectionSubscribers
+	w.subscribersMu.Unlock()
+	for _, fn := range subscribers {
+		fn(old, config)
+	}
+	for _, s := range sectionSubscribers {
+		if old != nil && !reflect.DeepEqual(sectionOf(old, s.section, s.subsection), sectionOf(config, s.section, s.subsection)) {
+			s.fn(old, config)
+		}
+	}
+	return true, nil
+}
+
+// sectionOf returns the value of a section or subsection of config, for
+// comparing with reflect.DeepEqual. An empty subsection stands for the whole
+// section. It returns nil if a subsection doesn't exist.
+func sectionOf(config interface{}, section, subsection string) interface{} {
+	sectionValue, _ := fieldFold(reflect.ValueOf(config).Elem(), section)
+	if sectionValue.Kind() != reflect.Map || subsection == "" {
+		return sectionValue.Interface()
+	}
+	if sectionValue.Type().Elem().Kind() == reflect.String {
+		if variables, ok := decodeStringMap(sectionValue)[subsection]; ok {
+			return variables
+		}
+		return nil
+	}
+	if subsectionValue := sectionValue.MapIndex(reflect.ValueOf(subsection).Convert(sectionValue.Type().Key())); subsectionValue.IsValid() {
+		return subsectionValue.Interface()
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package gcfg
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type watchConfig struct {
+	Section struct {
+		Name  string
+		Level int
+	}
+}
+
+// writeWatchedFile replaces the file atomically, so that a watcher polling it
+// never reads it half-written.
+func writeWatchedFile(t *testing.T, filename, data string, modTime time.Time) {
+	tmp := filename + ".tmp"
+	require.NoError(t, os.WriteFile(tmp, []byte(data), 0644))
+	require.NoError(t, os.Chtimes(tmp, modTime, modTime))
+	require.NoError(t, os.Rename(tmp, filename))
+}
+
+func TestWatcher(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.gcfg")
+	now := time.Now()
+	writeWatchedFile(t, filename, "[section]\nname = a\n", now)
+
+	w, err := NewWatcher(filename, func(c *watchConfig) { c.Section.Level = 1 })
+	require.NoError(t, err)
+	first := w.Load()
+	assert.Equal(t, "a", first.Section.Name)
+	assert.Equal(t, 1, first.Section.Level)
+
+	var notified [][2]*watchConfig
+	w.Subscribe(func(old, new *watchConfig) { notified = append(notified, [2]*watchConfig{old, new}) })
+
+	// Unchanged file.
+	reloaded, err := w.Check()
+	assert.NoError(t, err)
+	assert.False(t, reloaded)
+
+	// Touched, but with the same contents.
+	writeWatchedFile(t, filename, "[section]\nname = a\n", now.Add(time.Second))
+	reloaded, err = w.Check()
+	assert.NoError(t, err)
+	assert.False(t, reloaded)
+
+	writeWatchedFile(t, filename, "[section]\nname = b\nlevel = 2\n", now.Add(2*time.Second))
+	reloaded, err = w.Check()
+	assert.NoError(t, err)
+	assert.True(t, reloaded)
+	second := w.Load()
+	assert.Equal(t, "b", second.Section.Name)
+	assert.Equal(t, 2, second.Section.Level)
+	assert.Equal(t, "a", first.Section.Name)
+	assert.Equal(t, [][2]*watchConfig{{first, second}}, notified)
+
+	// A failed reload keeps the old config, and isn't retried until the file
+	// changes again.
+	writeWatchedFile(t, filename, "[section]\nlevel = x\n", now.Add(3*time.Second))
+	reloaded, err = w.Check()
+	assert.Error(t, err)
+	assert.False(t, reloaded)
+	assert.Same(t, second, w.Load())
+	reloaded, err = w.Check()
+	assert.NoError(t, err)
+	assert.False(t, reloaded)
+	assert.Len(t, notified, 1)
+
+	writeWatchedFile(t, filename, "[section]\nname = c\n", now.Add(4*time.Second))
+	reloaded, err = w.Check()
+	assert.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, "c", w.Load().Section.Name)
+
+	// Reload reads the file even if it hasn't changed.
+	third := w.Load()
+	require.NoError(t, w.Reload())
+	assert.NotSame(t, third, w.Load())
+	assert.Len(t, notified, 3)
+}
+
+func TestNewWatcherError(t *testing.T) {
+	_, err := NewWatcher[watchConfig](filepath.Join(t.TempDir(), "missing.gcfg"), nil)
+	assert.Error(t, err)
+
+	filename := filepath.Join(t.TempDir(), "config.gcfg")
+	writeWatchedFile(t, filename, "[section]\nname = a\n", time.Now())
+	_, err = NewWatcher[int](filename, nil)
+	assert.EqualError(t, err, "Config must be a struct, instead it is of int type")
+}
+
+func TestWatcherRun(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.gcfg")
+	writeWatchedFile(t, filename, "[section]\nname = a\n", time.Now())
+	w, err := NewWatcher[watchConfig](filename, nil)
+	require.NoError(t, err)
+
+	errs := make(chan error, 1)
+	w.OnError = func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx, time.Millisecond) }()
+
+	writeWatchedFile(t, filename, "[section]\nunknown = a\n", time.Now().Add(time.Second))
+	assert.Error(t, <-errs)
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+	assert.Equal(t, "a", w.Load().Section.Name)
+}
+
+func TestWatcherSubscribeSection(t *testing.T) {
+	type config struct {
+		Display struct{ Color bool }
+		Cache   struct{ Dir string }
+		Remote  map[string]*struct{ URL string }
+		Alias   map[string]string
+	}
+	filename := filepath.Join(t.TempDir(), "config.gcfg")
+	now := time.Now()
+	writeWatchedFile(t, filename, "[cache]\ndir = a\n[remote \"origin\"]\nurl = x\n[alias \"ls\"]\ncmd = list\n", now)
+	w, err := NewWatcher[config](filename, nil)
+	require.NoError(t, err)
+
+	notified := map[string]int{}
+	for _, s := range [][2]string{{"display", ""}, {"cache", ""}, {"remote", "origin"}, {"remote", "other"}, {"remote", ""}, {"alias", "ls"}, {"alias", ""}} {
+		name := s[0] + " " + s[1]
+		require.NoError(t, w.SubscribeSection(s[0], s[1], func(old, new *config) { notified[name]++ }))
+	}
+	assert.Error(t, w.SubscribeSection("missing", "", func(old, new *config) {}))
+	assert.Error(t, w.SubscribeSection("cache", "sub", func(old, new *config) {}))
+
+	writeWatchedFile(t, filename, "[display]\ncolor\n[cache]\ndir = a\n[remote \"origin\"]\nurl = x\n[alias \"ls\"]\ncmd = list\n", now.Add(time.Second))
+	_, err = w.Check()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"display ": 1}, notified)
+
+	writeWatchedFile(t, filename, "[display]\ncolor\n[cache]\ndir = b\n[remote \"origin\"]\nurl = y\n[remote \"other\"]\n[alias \"ls\"]\ncmd = ls\n", now.Add(2*time.Second))
+	_, err = w.Check()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"display ": 1, "cache ": 1, "remote origin": 1, "remote other": 1, "remote ": 1, "alias ls": 1, "alias ": 1}, notified)
+
+	// Only the whole-section subscribers see subsections that weren't
+	// subscribed to by name.
+	writeWatchedFile(t, filename, "[display]\ncolor\n[cache]\ndir = b\n[remote \"origin\"]\nurl = y\n[remote \"other\"]\n[remote \"new\"]\nurl = z\n[alias \"ls\"]\ncmd = ls\n[alias]\nll = list\n", now.Add(3*time.Second))
+	_, err = w.Check()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"display ": 1, "cache ": 1, "remote origin": 1, "remote other": 1, "remote ": 2, "alias ls": 1, "alias ": 2}, notified)
+}